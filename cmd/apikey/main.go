@@ -0,0 +1,114 @@
+// Утилита для управления API-ключами без обращения к HTTP API:
+//
+//	apikey create --name batch --scope subscriptions:read --scope reports:read --ttl 720h
+//	apikey list
+//	apikey revoke <id>
+//
+// Конфигурация читается из CONFIG_PATH (по умолчанию configs/local.yaml).
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"SubServices/internal/apikeys"
+	"SubServices/internal/config"
+	"SubServices/internal/storage"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	configPath := os.Getenv("CONFIG_PATH")
+	if configPath == "" {
+		configPath = "configs/local.yaml"
+	}
+	cfg, err := config.MustLoadConfig(configPath)
+	if err != nil {
+		fail(err)
+	}
+
+	pool, err := storage.NewPool(cfg.StoragePath)
+	if err != nil {
+		fail(err)
+	}
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	store := apikeys.NewStore(pool)
+
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "create":
+		flags := pflag.NewFlagSet("create", pflag.ExitOnError)
+		name := flags.String("name", "", "название ключа")
+		scopes := flags.StringSlice("scope", nil, "scope ключа: "+strings.Join(apikeys.Scopes, ", "))
+		ttl := flags.Duration("ttl", 0, "срок действия ключа (0 — бессрочный)")
+		flags.Parse(args)
+
+		if *name == "" || len(*scopes) == 0 {
+			fail(fmt.Errorf("--name and at least one --scope are required"))
+		}
+		var expiresAt *time.Time
+		if *ttl > 0 {
+			t := time.Now().Add(*ttl)
+			expiresAt = &t
+		}
+
+		k, token, err := store.Create(ctx, *name, *scopes, expiresAt)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Printf("id:  %s\nkey: %s\n", k.ID, token)
+
+	case "list":
+		keys, err := store.List(ctx)
+		if err != nil {
+			fail(err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tSCOPES\tLAST USED\tEXPIRES")
+		for _, k := range keys {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				k.ID, k.Name, k.Prefix, strings.Join(k.Scopes, ","), formatTime(k.LastUsedAt), formatTime(k.ExpiresAt))
+		}
+		tw.Flush()
+
+	case "revoke":
+		if len(args) != 1 {
+			usage()
+		}
+		if err := store.Revoke(ctx, args[0]); err != nil {
+			fail(err)
+		}
+
+	default:
+		usage()
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: apikey create --name <name> --scope <scope>... [--ttl <duration>] | list | revoke <id>")
+	os.Exit(2)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
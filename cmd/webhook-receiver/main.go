@@ -0,0 +1,61 @@
+// Локальный получатель webhook'ов для проверки интеграций: принимает доставки,
+// проверяет подпись и печатает события в лог.
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"SubServices/internal/webhooks"
+)
+
+func main() {
+	addr := pflag.String("addr", "localhost:9090", "адрес для входящих запросов")
+	secret := pflag.String("secret", "", "секрет webhook, полученный при регистрации")
+	failRate := pflag.Int("fail-every", 0, "отвечать 500 на каждый n-й запрос, чтобы проверить повторы")
+	pflag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	var received atomic.Int64
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "read error", http.StatusBadRequest)
+			return
+		}
+
+		if *secret != "" {
+			if err := webhooks.Verify(*secret, r.Header.Get(webhooks.HeaderSignature), body, 5*time.Minute); err != nil {
+				logger.Warn("Rejected delivery", slog.String("delivery", r.Header.Get(webhooks.HeaderDelivery)), slog.Any("error", err))
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		n := received.Add(1)
+		if *failRate > 0 && n%int64(*failRate) == 0 {
+			logger.Info("Simulating failure", slog.String("delivery", r.Header.Get(webhooks.HeaderDelivery)))
+			http.Error(w, "simulated failure", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("Delivery received",
+			slog.String("event", r.Header.Get(webhooks.HeaderEvent)),
+			slog.String("delivery", r.Header.Get(webhooks.HeaderDelivery)),
+			slog.String("body", string(body)))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	logger.Info("Listening for webhooks", slog.String("addr", *addr))
+	if err := http.ListenAndServe(*addr, nil); err != nil {
+		logger.Error("Receiver failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
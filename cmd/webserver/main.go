@@ -8,11 +8,20 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
+	"SubServices/internal/apikeys"
+	"SubServices/internal/changes"
 	"SubServices/internal/config"
+	"SubServices/internal/events"
+	"SubServices/internal/http/auth"
 	"SubServices/internal/http/handlers"
 	"SubServices/internal/http/router"
+	"SubServices/internal/lifecycle"
+	"SubServices/internal/outbox"
+	"SubServices/internal/scheduler"
 	"SubServices/internal/storage"
+	"SubServices/internal/webhooks"
 )
 
 // @title SubServices API
@@ -47,9 +56,20 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Без auth.enabled authn остаётся nil и запросы не проверяются.
+	var authn *auth.Authenticator
+	if cfg.Auth.Enabled {
+		authn, err = auth.New(cfg.Auth, apikeys.NewStore(pool))
+		if err != nil {
+			slog.Error("Failed to init auth", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
 	// Инициализация HTTP
-	h := handlers.NewHandler(pool)
-	r := router.InitRouter(h)
+	broker := events.NewBroker(cfg.Stream.LogSize)
+	h := handlers.NewHandler(pool, cfg, broker)
+	r := router.InitRouter(h, authn)
 
 	srv := &http.Server{
 		Addr:         cfg.HttpServer.Host,
@@ -59,6 +79,32 @@ func main() {
 		IdleTimeout:  cfg.HttpServer.IdleTimeout,
 	}
 
+	// Фоновые задачи
+	sinks := append(scheduler.NewSinks(cfg.Scheduler.Reminders), scheduler.NewEventSink(outbox.NewPublisher(pool)))
+	sched := scheduler.New(cfg.Scheduler.Interval,
+		scheduler.NewReminderJob(pool, cfg.Scheduler.Reminders, sinks...),
+		changes.NewJob(pool),
+		lifecycle.NewRenewalJob(pool, cfg.Scheduler.Renewals),
+		lifecycle.NewStatusJob(pool),
+	)
+	if cfg.Scheduler.Enabled {
+		slog.Info("Starting scheduler", slog.Duration("interval", cfg.Scheduler.Interval))
+		sched.Start()
+	}
+
+	// События из outbox передаются в очередь webhook'ов
+	dispatcher := scheduler.New(cfg.Outbox.PollInterval, outbox.NewDispatcher(pool, webhooks.NewPublisher(pool), cfg.Outbox))
+	dispatcher.Start()
+
+	deliveries := scheduler.New(cfg.Webhooks.PollInterval, webhooks.NewDeliveryJob(pool, cfg.Webhooks))
+	deliveries.Start()
+
+	// Изменения подписок от всех экземпляров сервиса через LISTEN/NOTIFY
+	listener := storage.NewListener(pool, cfg.Listener.ReconnectMinDelay, cfg.Listener.ReconnectMaxDelay)
+	listener.Subscribe(h.PublishChange)
+	listener.Subscribe(func(context.Context, storage.Notification) { sched.Trigger() })
+	listener.Start()
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
@@ -82,6 +128,11 @@ func main() {
 		slog.Info("Server stopped gracefully")
 	}
 
+	listener.Stop()
+	sched.Stop()
+	dispatcher.Stop()
+	deliveries.Stop()
+
 	pool.Close()
 }
 
@@ -0,0 +1,185 @@
+// Package apikeys хранит ключи доступа для межсервисных вызовов.
+// Ключ имеет вид "<prefix>.<secret>": по prefix запись находится в таблице
+// api_keys, а secret хранится только в виде SHA-256 с солью.
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	ScopeSubscriptionsRead  = "subscriptions:read"
+	ScopeSubscriptionsWrite = "subscriptions:write"
+	ScopeReportsRead        = "reports:read"
+	ScopeAdmin              = "admin"
+)
+
+// Scopes перечисляет все допустимые scope.
+var Scopes = []string{ScopeSubscriptionsRead, ScopeSubscriptionsWrite, ScopeReportsRead, ScopeAdmin}
+
+var (
+	ErrInvalidKey = errors.New("invalid api key")
+	ErrExpired    = errors.New("api key expired")
+)
+
+// lastUsedResolution — не чаще, чем раз в этот интервал, обновляется last_used_at,
+// чтобы частые запросы не превращались в поток UPDATE.
+const lastUsedResolution = time.Minute
+
+type Key struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// DB — общий интерфейс pgxpool.Pool и pgx.Tx, нужный Store.
+type DB interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type Store struct {
+	db DB
+}
+
+func NewStore(db DB) *Store {
+	return &Store{db: db}
+}
+
+func IsKnownScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Create выпускает ключ и возвращает его запись и сам ключ. Ключ показывается
+// только один раз: в базе остаётся лишь его хеш.
+func (s *Store) Create(ctx context.Context, name string, scopes []string, expiresAt *time.Time) (Key, string, error) {
+	for _, scope := range scopes {
+		if !IsKnownScope(scope) {
+			return Key{}, "", fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+
+	prefix := make([]byte, 6)
+	secret := make([]byte, 32)
+	salt := make([]byte, 16)
+	for _, buf := range [][]byte{prefix, secret, salt} {
+		if _, err := rand.Read(buf); err != nil {
+			return Key{}, "", err
+		}
+	}
+
+	k := Key{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Prefix:    hex.EncodeToString(prefix),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	secretStr := base64.RawURLEncoding.EncodeToString(secret)
+
+	query := `
+		INSERT INTO api_keys (id, name, prefix, salt, hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at
+	`
+	err := s.db.QueryRow(ctx, query, k.ID, k.Name, k.Prefix, salt, hash(salt, secretStr), k.Scopes, k.ExpiresAt).
+		Scan(&k.CreatedAt)
+	if err != nil {
+		return Key{}, "", err
+	}
+	return k, k.Prefix + "." + secretStr, nil
+}
+
+func (s *Store) List(ctx context.Context) ([]Key, error) {
+	query := `SELECT id, name, prefix, scopes, created_at, last_used_at, expires_at FROM api_keys ORDER BY created_at`
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, scanKey)
+}
+
+// Revoke удаляет ключ. Возвращает pgx.ErrNoRows, если ключа нет.
+func (s *Store) Revoke(ctx context.Context, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM api_keys WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// Authenticate проверяет ключ из заголовка и отмечает его использование.
+func (s *Store) Authenticate(ctx context.Context, token string) (Key, error) {
+	prefix, secret, ok := strings.Cut(token, ".")
+	if !ok || prefix == "" || secret == "" {
+		return Key{}, ErrInvalidKey
+	}
+
+	var (
+		k          Key
+		salt, want []byte
+	)
+	query := `SELECT id, name, prefix, scopes, created_at, last_used_at, expires_at, salt, hash FROM api_keys WHERE prefix = $1`
+	err := s.db.QueryRow(ctx, query, prefix).
+		Scan(&k.ID, &k.Name, &k.Prefix, &k.Scopes, &k.CreatedAt, &k.LastUsedAt, &k.ExpiresAt, &salt, &want)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Key{}, ErrInvalidKey
+	}
+	if err != nil {
+		return Key{}, err
+	}
+
+	if subtle.ConstantTimeCompare(hash(salt, secret), want) != 1 {
+		return Key{}, ErrInvalidKey
+	}
+	if k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt) {
+		return Key{}, ErrExpired
+	}
+
+	if k.LastUsedAt == nil || time.Since(*k.LastUsedAt) > lastUsedResolution {
+		query := `UPDATE api_keys SET last_used_at = now() WHERE id = $1`
+		if _, err := s.db.Exec(ctx, query, k.ID); err != nil {
+			return Key{}, err
+		}
+	}
+	return k, nil
+}
+
+func scanKey(row pgx.CollectableRow) (Key, error) {
+	var k Key
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.Scopes, &k.CreatedAt, &k.LastUsedAt, &k.ExpiresAt)
+	return k, err
+}
+
+func hash(salt []byte, secret string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(secret))
+	return h.Sum(nil)
+}
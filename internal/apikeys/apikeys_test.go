@@ -0,0 +1,210 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeDB хранит api_keys в памяти и понимает только запросы Store.
+type fakeDB struct {
+	rows    map[string]*fakeKey // по prefix
+	touches int
+}
+
+type fakeKey struct {
+	key        Key
+	salt, hash []byte
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{rows: make(map[string]*fakeKey)}
+}
+
+func (db *fakeDB) QueryRow(_ context.Context, sql string, args ...any) pgx.Row {
+	switch {
+	case strings.Contains(sql, "INSERT INTO api_keys"):
+		k := Key{
+			ID:        args[0].(string),
+			Name:      args[1].(string),
+			Prefix:    args[2].(string),
+			Scopes:    args[5].([]string),
+			CreatedAt: time.Now(),
+			ExpiresAt: args[6].(*time.Time),
+		}
+		db.rows[k.Prefix] = &fakeKey{key: k, salt: args[3].([]byte), hash: args[4].([]byte)}
+		return fakeRow{values: []any{k.CreatedAt}}
+	case strings.Contains(sql, "WHERE prefix = $1"):
+		r, ok := db.rows[args[0].(string)]
+		if !ok {
+			return fakeRow{err: pgx.ErrNoRows}
+		}
+		k := r.key
+		return fakeRow{values: []any{k.ID, k.Name, k.Prefix, k.Scopes, k.CreatedAt, k.LastUsedAt, k.ExpiresAt, r.salt, r.hash}}
+	}
+	return fakeRow{err: errors.New("unexpected query: " + sql)}
+}
+
+func (db *fakeDB) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	id := args[0].(string)
+	for prefix, r := range db.rows {
+		if r.key.ID != id {
+			continue
+		}
+		switch {
+		case strings.Contains(sql, "UPDATE api_keys SET last_used_at"):
+			now := time.Now()
+			r.key.LastUsedAt = &now
+			db.touches++
+			return pgconn.NewCommandTag("UPDATE 1"), nil
+		case strings.Contains(sql, "DELETE FROM api_keys"):
+			delete(db.rows, prefix)
+			return pgconn.NewCommandTag("DELETE 1"), nil
+		}
+	}
+	return pgconn.NewCommandTag("DELETE 0"), nil
+}
+
+func (db *fakeDB) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return nil, errors.New("not implemented")
+}
+
+type fakeRow struct {
+	values []any
+	err    error
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	for i, d := range dest {
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(r.values[i]))
+	}
+	return nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDB()
+	s := NewStore(db)
+
+	_, token, err := s.Create(ctx, "billing", []string{ScopeSubscriptionsRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix, secret, _ := strings.Cut(token, ".")
+
+	past := time.Now().Add(-time.Hour)
+	_, expired, err := s.Create(ctx, "old", []string{ScopeSubscriptionsRead}, &past)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredPrefix, _, _ := strings.Cut(expired, ".")
+
+	revokedKey, revoked, err := s.Create(ctx, "revoked", []string{ScopeSubscriptionsRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Revoke(ctx, revokedKey.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{name: "valid", token: token, want: nil},
+		{name: "empty", token: "", want: ErrInvalidKey},
+		{name: "no separator", token: prefix + secret, want: ErrInvalidKey},
+		{name: "empty prefix", token: "." + secret, want: ErrInvalidKey},
+		{name: "empty secret", token: prefix + ".", want: ErrInvalidKey},
+		{name: "unknown prefix", token: "000000000000." + secret, want: ErrInvalidKey},
+		{name: "hash mismatch", token: prefix + ".wrong", want: ErrInvalidKey},
+		{name: "secret of another key", token: expiredPrefix + "." + secret, want: ErrInvalidKey},
+		{name: "trailing data", token: token + ".extra", want: ErrInvalidKey},
+		{name: "expired", token: expired, want: ErrExpired},
+		{name: "expired with wrong secret", token: expiredPrefix + ".wrong", want: ErrInvalidKey},
+		{name: "revoked", token: revoked, want: ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := s.Authenticate(ctx, tt.token)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Authenticate(%q) error = %v, want %v", tt.token, err, tt.want)
+			}
+			if err == nil && k.Prefix != prefix {
+				t.Fatalf("Authenticate() prefix = %q, want %q", k.Prefix, prefix)
+			}
+		})
+	}
+}
+
+func TestAuthenticateTouchesLastUsed(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDB()
+	s := NewStore(db)
+
+	_, token, err := s.Create(ctx, "billing", []string{ScopeSubscriptionsRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if _, err := s.Authenticate(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// last_used_at обновляется не чаще раза в lastUsedResolution.
+	if db.touches != 1 {
+		t.Fatalf("last_used_at updated %d times, want 1", db.touches)
+	}
+}
+
+func TestRevokeUnknown(t *testing.T) {
+	s := NewStore(newFakeDB())
+	if err := s.Revoke(context.Background(), "60601fee-2bf1-4721-ae6f-7636e79a0cba"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("Revoke() error = %v, want %v", err, pgx.ErrNoRows)
+	}
+}
+
+func TestScopes(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(newFakeDB())
+
+	scopes := []string{ScopeSubscriptionsRead, ScopeReportsRead}
+	_, token, err := s.Create(ctx, "reports", scopes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := s.Authenticate(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(k.Scopes, scopes) {
+		t.Fatalf("Authenticate() scopes = %v, want %v", k.Scopes, scopes)
+	}
+
+	if _, _, err := s.Create(ctx, "bad", []string{ScopeSubscriptionsRead, "subscriptions:delete"}, nil); err == nil {
+		t.Fatal("Create() with unknown scope succeeded, want error")
+	}
+
+	for _, scope := range Scopes {
+		if !IsKnownScope(scope) {
+			t.Errorf("IsKnownScope(%q) = false, want true", scope)
+		}
+	}
+	for _, scope := range []string{"", "Admin", "subscriptions", "subscriptions:read "} {
+		if IsKnownScope(scope) {
+			t.Errorf("IsKnownScope(%q) = true, want false", scope)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package billing
+
+import (
+	"fmt"
+	"time"
+)
+
+// Proration — способ расчёта стоимости месяца, оплаченного не полностью.
+type Proration string
+
+const (
+	// ProrationNone — месяц, в котором подписка действовала хотя бы день, оплачивается целиком.
+	ProrationNone Proration = "none"
+	// ProrationDaily — оплачиваются дни, в которые подписка действовала.
+	ProrationDaily Proration = "daily"
+	// ProrationHalfMonth — месяц делится на половины (1–15 и 16–конец месяца),
+	// каждая половина с хотя бы одним днём подписки оплачивается целиком.
+	ProrationHalfMonth Proration = "half_month"
+)
+
+func ParseProration(v string) (Proration, error) {
+	switch p := Proration(v); p {
+	case ProrationNone, ProrationDaily, ProrationHalfMonth:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown proration %q", v)
+}
+
+// Item — минимальное представление подписки, достаточное для расчёта стоимости.
+// StartDate и EndDate — первый и последний оплачиваемые дни включительно.
+// До TrialEnd включительно вместо Price действует TrialPrice (nil — бесплатно).
+// Дни внутри Pauses не оплачиваются. Price — текущая цена; до месяцев из PriceChanges
+// действовали прежние цены.
+type Item struct {
+	ID           string
+	ServiceName  string
+	Price        int
+	StartDate    time.Time
+	EndDate      *time.Time
+	TrialEnd     *time.Time
+	TrialPrice   *int
+	Pauses       []Pause
+	PriceChanges []PriceChange
+}
+
+// PriceChange — смена цены с первого дня месяца From; до него действовала цена Previous.
+type PriceChange struct {
+	From     time.Time
+	Previous int
+}
+
+// PriceIn возвращает цену подписки в месяце month: Previous ближайшей следующей
+// смены цены или Price, если после month цена не менялась. PriceChanges должны
+// быть упорядочены по From.
+func (it Item) PriceIn(month time.Time) int {
+	m := MonthStart(month)
+	for _, c := range it.PriceChanges {
+		if m.Before(MonthStart(c.From)) {
+			return c.Previous
+		}
+	}
+	return it.Price
+}
+
+// Pause — приостановка оплаты с From по Until включительно; Until == nil — бессрочно.
+type Pause struct {
+	From  time.Time
+	Until *time.Time
+}
+
+func (p Pause) Covers(d time.Time) bool {
+	return !d.Before(day(p.From)) && (p.Until == nil || !d.After(day(*p.Until)))
+}
+
+// MonthStart приводит дату к первому числу месяца.
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// MonthEnd возвращает последний день месяца даты t.
+func MonthEnd(t time.Time) time.Time {
+	return MonthStart(t).AddDate(0, 1, -1)
+}
+
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// ActiveIn сообщает, оплачивается ли подписка хотя бы один день указанного месяца.
+func (it Item) ActiveIn(month time.Time) bool {
+	from, to, ok := it.activeDays(month)
+	return ok && it.segment(from, to).days > 0
+}
+
+// activeDays возвращает первый и последний дни подписки внутри месяца month.
+func (it Item) activeDays(month time.Time) (from, to time.Time, ok bool) {
+	from, to = MonthStart(month), MonthEnd(month)
+	if start := day(it.StartDate); start.After(from) {
+		from = start
+	}
+	if it.EndDate != nil {
+		if end := day(*it.EndDate); end.Before(to) {
+			to = end
+		}
+	}
+	return from, to, !from.After(to)
+}
+
+// dayRate возвращает месячную цену, действующую в день d, и false, если день на паузе.
+func (it Item) dayRate(d time.Time) (int, bool) {
+	for _, p := range it.Pauses {
+		if p.Covers(d) {
+			return 0, false
+		}
+	}
+	if it.TrialEnd != nil && !d.After(day(*it.TrialEnd)) {
+		if it.TrialPrice != nil {
+			return *it.TrialPrice, true
+		}
+		return 0, true
+	}
+	return it.Price, true
+}
+
+// segment — оплачиваемые дни отрезка.
+type segment struct {
+	days int
+	// sum — сумма месячных цен по оплачиваемым дням.
+	sum int
+	// full — есть оплачиваемый день после пробного периода.
+	full bool
+}
+
+func (it Item) segment(from, to time.Time) segment {
+	var seg segment
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		rate, billed := it.dayRate(d)
+		if !billed {
+			continue
+		}
+		seg.days++
+		seg.sum += rate
+		if it.TrialEnd == nil || d.After(day(*it.TrialEnd)) {
+			seg.full = true
+		}
+	}
+	return seg
+}
+
+// rate возвращает цену отрезка, оплачиваемого целиком: полную, если в нём есть день
+// после пробного периода, пробную, если есть только пробные дни, и 0, если отрезок на паузе.
+func (it Item) rate(seg segment) int {
+	switch {
+	case seg.full:
+		return it.Price
+	case seg.days == 0:
+		return 0
+	case it.TrialPrice != nil:
+		return *it.TrialPrice
+	default:
+		return 0
+	}
+}
+
+// Cost возвращает стоимость подписки в указанном месяце.
+//
+// Правила округления: при ProrationDaily стоимость месяца равна
+// Σ цена дня / дни месяца с округлением до целого, половина — вверх, где цена дня —
+// price или trial_price, а дни на паузе не учитываются. При ProrationHalfMonth
+// первая половина стоит price − price/2, вторая — price/2, так что полный месяц
+// всегда стоит ровно price. Месяц или половина, в которых есть хотя бы один день
+// после пробного периода, оплачиваются по полной цене, целиком на паузе — бесплатны.
+// Округляется стоимость каждой подписки за каждый месяц, итоги — суммы округлённых значений.
+// Цена меняется только с начала месяца, поэтому внутри месяца действует одна PriceIn.
+func (it Item) Cost(month time.Time, mode Proration) int {
+	from, to, ok := it.activeDays(month)
+	if !ok {
+		return 0
+	}
+	it.Price = it.PriceIn(month)
+
+	switch mode {
+	case ProrationDaily:
+		total := MonthEnd(month).Day()
+		return (2*it.segment(from, to).sum + total) / (2 * total)
+	case ProrationHalfMonth:
+		mid := MonthStart(month).AddDate(0, 0, 15)
+		cost := 0
+		if from.Before(mid) {
+			p := it.rate(it.segment(from, minTime(to, mid.AddDate(0, 0, -1))))
+			cost += p - p/2
+		}
+		if !to.Before(mid) {
+			p := it.rate(it.segment(maxTime(from, mid), to))
+			cost += p / 2
+		}
+		return cost
+	default:
+		return it.rate(it.segment(from, to))
+	}
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// Total суммирует стоимость подписок за месяц.
+func Total(items []Item, month time.Time, mode Proration) int {
+	total := 0
+	for _, it := range items {
+		total += it.Cost(month, mode)
+	}
+	return total
+}
+
+// Months возвращает список месяцев из диапазона [from, to] включительно.
+func Months(from, to time.Time) []time.Time {
+	var months []time.Time
+	for m := MonthStart(from); !m.After(MonthStart(to)); m = m.AddDate(0, 1, 0) {
+		months = append(months, m)
+	}
+	return months
+}
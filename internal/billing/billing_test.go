@@ -0,0 +1,273 @@
+package billing
+
+import (
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestCost(t *testing.T) {
+	jan := date(2026, time.January, 1)
+	feb := date(2026, time.February, 1)
+	dec := date(2025, time.December, 1)
+
+	tests := []struct {
+		name  string
+		item  Item
+		month time.Time
+		// none, daily, half_month
+		want [3]int
+	}{
+		{
+			name:  "full month",
+			item:  Item{Price: 300, StartDate: dec},
+			month: jan,
+			want:  [3]int{300, 300, 300},
+		},
+		{
+			name:  "starts mid-month",
+			item:  Item{Price: 300, StartDate: date(2026, time.January, 16)},
+			month: jan,
+			// daily: 16 × 300 / 31 = 154.84
+			want: [3]int{300, 155, 150},
+		},
+		{
+			name:  "ends mid-month",
+			item:  Item{Price: 300, StartDate: dec, EndDate: ptr(date(2026, time.January, 10))},
+			month: jan,
+			// daily: 10 × 300 / 31 = 96.77
+			want: [3]int{300, 97, 150},
+		},
+		{
+			name:  "starts and ends in the same half",
+			item:  Item{Price: 300, StartDate: date(2026, time.January, 3), EndDate: ptr(date(2026, time.January, 5))},
+			month: jan,
+			// daily: 3 × 300 / 31 = 29.03
+			want: [3]int{300, 29, 150},
+		},
+		{
+			name:  "first half of an odd price",
+			item:  Item{Price: 301, StartDate: dec, EndDate: ptr(date(2026, time.January, 15))},
+			month: jan,
+			// daily: 15 × 301 / 31 = 145.65
+			want: [3]int{301, 146, 151},
+		},
+		{
+			name:  "second half of an odd price",
+			item:  Item{Price: 301, StartDate: date(2026, time.January, 16)},
+			month: jan,
+			// daily: 16 × 301 / 31 = 155.35
+			want: [3]int{301, 155, 150},
+		},
+		{
+			name:  "february split across halves",
+			item:  Item{Price: 300, StartDate: date(2026, time.February, 15)},
+			month: feb,
+			// daily: 14 × 300 / 28 = 150; 15.02 попадает в первую половину
+			want: [3]int{300, 150, 300},
+		},
+		{
+			name:  "february half rounds up",
+			item:  Item{Price: 3, StartDate: date(2026, time.February, 15)},
+			month: feb,
+			// daily: 14 × 3 / 28 = 1.5
+			want: [3]int{3, 2, 3},
+		},
+		{
+			name:  "leap february",
+			item:  Item{Price: 290, StartDate: date(2028, time.February, 20)},
+			month: date(2028, time.February, 1),
+			// daily: 10 × 290 / 29 = 100
+			want: [3]int{290, 100, 145},
+		},
+		{
+			name:  "trial ends mid-month with trial price",
+			item:  Item{Price: 310, StartDate: jan, TrialEnd: ptr(date(2026, time.January, 10)), TrialPrice: ptr(100)},
+			month: jan,
+			// daily: (10 × 100 + 21 × 310) / 31 = 242.26; в первой половине есть дни по полной цене
+			want: [3]int{310, 242, 310},
+		},
+		{
+			name:  "free trial ends in the second half",
+			item:  Item{Price: 310, StartDate: jan, TrialEnd: ptr(date(2026, time.January, 20))},
+			month: jan,
+			// daily: 11 × 310 / 31 = 110; первая половина целиком в пробном периоде
+			want: [3]int{310, 110, 155},
+		},
+		{
+			name:  "trial covers the whole month",
+			item:  Item{Price: 310, StartDate: jan, TrialEnd: ptr(date(2026, time.February, 14)), TrialPrice: ptr(100)},
+			month: jan,
+			want:  [3]int{100, 100, 100},
+		},
+		{
+			name: "pause from previous month, first month",
+			item: Item{Price: 310, StartDate: dec, Pauses: []Pause{
+				{From: date(2025, time.December, 20), Until: ptr(date(2026, time.January, 5))},
+			}},
+			month: dec,
+			// daily: 19 × 310 / 31 = 190
+			want: [3]int{310, 190, 310},
+		},
+		{
+			name: "pause from previous month, second month",
+			item: Item{Price: 310, StartDate: dec, Pauses: []Pause{
+				{From: date(2025, time.December, 20), Until: ptr(date(2026, time.January, 5))},
+			}},
+			month: jan,
+			// daily: 26 × 310 / 31 = 260
+			want: [3]int{310, 260, 310},
+		},
+		{
+			name: "pause covers the second half and continues",
+			item: Item{Price: 310, StartDate: dec, Pauses: []Pause{
+				{From: date(2026, time.January, 16), Until: ptr(date(2026, time.February, 10))},
+			}},
+			month: jan,
+			// daily: 15 × 310 / 31 = 150
+			want: [3]int{310, 150, 155},
+		},
+		{
+			name: "pause ends in february",
+			item: Item{Price: 310, StartDate: dec, Pauses: []Pause{
+				{From: date(2026, time.January, 16), Until: ptr(date(2026, time.February, 10))},
+			}},
+			month: feb,
+			// daily: 18 × 310 / 28 = 199.29; в первой половине есть дни после паузы
+			want: [3]int{310, 199, 310},
+		},
+		{
+			name: "open-ended pause",
+			item: Item{Price: 310, StartDate: dec, Pauses: []Pause{
+				{From: date(2026, time.January, 16)},
+			}},
+			month: feb,
+			want:  [3]int{0, 0, 0},
+		},
+		{
+			name: "pause during trial",
+			item: Item{Price: 310, StartDate: jan, TrialEnd: ptr(date(2026, time.January, 15)), TrialPrice: ptr(100), Pauses: []Pause{
+				{From: date(2026, time.January, 16), Until: ptr(date(2026, time.January, 31))},
+			}},
+			month: jan,
+			// Оплачиваются только пробные дни: 15 × 100 / 31 = 48.39
+			want: [3]int{100, 48, 50},
+		},
+		{
+			name: "price raised in a later month",
+			item: Item{Price: 620, StartDate: dec, PriceChanges: []PriceChange{
+				{From: feb, Previous: 310},
+			}},
+			month: jan,
+			want:  [3]int{310, 310, 310},
+		},
+		{
+			name: "price raised this month",
+			item: Item{Price: 620, StartDate: dec, PriceChanges: []PriceChange{
+				{From: feb, Previous: 310},
+			}},
+			month: feb,
+			want:  [3]int{620, 620, 620},
+		},
+		{
+			name: "several price changes",
+			item: Item{Price: 900, StartDate: dec, PriceChanges: []PriceChange{
+				{From: jan, Previous: 310},
+				{From: feb, Previous: 620},
+			}},
+			month: jan,
+			want:  [3]int{620, 620, 620},
+		},
+		{
+			name: "price change with trial and pause",
+			item: Item{Price: 620, StartDate: jan, TrialEnd: ptr(date(2026, time.January, 10)), TrialPrice: ptr(100), PriceChanges: []PriceChange{
+				{From: feb, Previous: 310},
+			}, Pauses: []Pause{
+				{From: date(2026, time.January, 21), Until: ptr(date(2026, time.January, 31))},
+			}},
+			month: jan,
+			// daily: (10 × 100 + 10 × 310) / 31 = 132.26
+			want: [3]int{310, 132, 310},
+		},
+		{
+			name:  "ended before the month",
+			item:  Item{Price: 300, StartDate: dec, EndDate: ptr(date(2025, time.December, 31))},
+			month: jan,
+			want:  [3]int{0, 0, 0},
+		},
+		{
+			name:  "starts after the month",
+			item:  Item{Price: 300, StartDate: feb},
+			month: jan,
+			want:  [3]int{0, 0, 0},
+		},
+	}
+
+	modes := [3]Proration{ProrationNone, ProrationDaily, ProrationHalfMonth}
+	for _, tt := range tests {
+		for i, mode := range modes {
+			t.Run(tt.name+"/"+string(mode), func(t *testing.T) {
+				if got := tt.item.Cost(tt.month, mode); got != tt.want[i] {
+					t.Fatalf("Cost(%s, %s) = %d, want %d", tt.month.Format("01-2006"), mode, got, tt.want[i])
+				}
+			})
+		}
+	}
+}
+
+func TestActiveIn(t *testing.T) {
+	it := Item{Price: 300, StartDate: date(2025, time.December, 1), Pauses: []Pause{
+		{From: date(2026, time.January, 1), Until: ptr(date(2026, time.January, 31))},
+	}}
+
+	tests := []struct {
+		month time.Time
+		want  bool
+	}{
+		{date(2025, time.November, 1), false},
+		{date(2025, time.December, 1), true},
+		{date(2026, time.January, 1), false},
+		{date(2026, time.February, 1), true},
+	}
+	for _, tt := range tests {
+		if got := it.ActiveIn(tt.month); got != tt.want {
+			t.Errorf("ActiveIn(%s) = %v, want %v", tt.month.Format("01-2006"), got, tt.want)
+		}
+	}
+}
+
+func TestTotalRoundsEachItem(t *testing.T) {
+	// Каждая подписка округляется отдельно: 2 × round(1.5) = 4, а не round(3).
+	items := []Item{
+		{Price: 3, StartDate: date(2026, time.February, 15)},
+		{Price: 3, StartDate: date(2026, time.February, 15)},
+	}
+	if got := Total(items, date(2026, time.February, 1), ProrationDaily); got != 4 {
+		t.Fatalf("Total() = %d, want 4", got)
+	}
+}
+
+func TestMonths(t *testing.T) {
+	got := Months(date(2025, time.November, 15), date(2026, time.February, 3))
+	want := []time.Time{
+		date(2025, time.November, 1),
+		date(2025, time.December, 1),
+		date(2026, time.January, 1),
+		date(2026, time.February, 1),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Months() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Fatalf("Months()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package catalog
+
+import (
+	"strings"
+	"unicode"
+)
+
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "h", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "sch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya", 'і': "i", 'ї': "yi", 'є': "ye",
+}
+
+// Key приводит название сервиса к ключу для сравнения: нижний регистр,
+// кириллица транслитерируется в латиницу, пробелы и знаки препинания
+// отбрасываются. Кириллическое «кс» транслитерируется в «x» (Яндекс → yandex,
+// Нетфликс → netflix); латиница остаётся как есть, чтобы books и boox не совпадали.
+// Написания, которые так не сводятся друг к другу, задаются алиасами сервиса.
+func Key(name string) string {
+	var b strings.Builder
+	runes := []rune(strings.ToLower(name))
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == 'к' && i+1 < len(runes) && runes[i+1] == 'с' {
+			b.WriteString("x")
+			i++
+			continue
+		}
+		if lat, ok := cyrillicToLatin[r]; ok {
+			b.WriteString(lat)
+			continue
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,67 @@
+package catalog
+
+import "testing"
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "latin", in: "Netflix", want: "netflix"},
+		{name: "cyrillic", in: "Нетфликс", want: "netflix"},
+		{name: "cyrillic yandex", in: "Яндекс Плюс", want: "yandexplyus"},
+		{name: "multi-letter transliteration", in: "Щука Жук Цирк Чай Шар Юла Яма", want: "schukazhuktsirkchaysharyulayama"},
+		{name: "yo and soft signs", in: "Ёлка подъезд соль", want: "elkapodezdsol"},
+		{name: "ukrainian letters", in: "Її Єва", want: "yiyiyeva"},
+
+		{name: "upper case", in: "NETFLIX", want: "netflix"},
+		{name: "cyrillic upper case", in: "НЕТФЛИКС", want: "netflix"},
+		{name: "surrounding whitespace", in: "  Netflix\t\n", want: "netflix"},
+		{name: "inner whitespace", in: "You Tube  Premium", want: "youtubepremium"},
+
+		{name: "ks inside a cyrillic word", in: "Яндекс", want: "yandex"},
+		{name: "ks at word start", in: "Ксерокс", want: "xerox"},
+		{name: "double k before s", in: "ккс", want: "kx"},
+		{name: "latin ks stays", in: "Books", want: "books"},
+		{name: "latin k, cyrillic s", in: "kс", want: "ks"},
+		{name: "cyrillic k, latin s", in: "кs", want: "ks"},
+		{name: "ks split by space", in: "к с", want: "ks"},
+		{name: "ks split by hyphen", in: "к-с", want: "ks"},
+		{name: "ks split by soft sign", in: "кьс", want: "ks"},
+
+		{name: "plus sign", in: "Disney+", want: "disney"},
+		{name: "punctuation", in: "Kino.Poisk — HD!", want: "kinopoiskhd"},
+		{name: "digits kept", in: "Okko 2.0", want: "okko20"},
+		{name: "other scripts kept", in: "Λάμδα", want: "λάμδα"},
+		{name: "only punctuation", in: " -+. ", want: ""},
+		{name: "empty", in: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Key(tt.in); got != tt.want {
+				t.Fatalf("Key(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyEquivalence(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"Нетфликс", "Netflix", true},
+		{"Яндекс Плюс", "yandex-plyus", true},
+		{"  NETFLIX ", "netflix", true},
+		{"Кинопоиск HD", "kinopoisk hd", true},
+		{"Books", "Boox", false},
+		// Такие расхождения транслитерации задаются алиасами.
+		{"Спотифай", "Spotify", false},
+	}
+	for _, tt := range tests {
+		if got := Key(tt.a) == Key(tt.b); got != tt.same {
+			t.Errorf("Key(%q) == Key(%q) is %v, want %v (%q vs %q)", tt.a, tt.b, got, tt.same, Key(tt.a), Key(tt.b))
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// Package changes применяет отложенные изменения подписок из scheduled_changes
+// с первого дня указанного месяца.
+package changes
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"SubServices/internal/events"
+	"SubServices/internal/lifecycle"
+	"SubServices/internal/outbox"
+	"SubServices/internal/storage"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusApplied   = "applied"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// DateLayout — формат end_date внутри Fields.
+const DateLayout = "2006-01-02"
+
+// Fields — изменяемые поля подписки; nil означает, что поле не меняется.
+type Fields struct {
+	Price *int `json:"price,omitempty"`
+	// EndDate — новый последний день подписки в формате DateLayout.
+	EndDate    *string `json:"end_date,omitempty"`
+	AutoRenew  *bool   `json:"auto_renew,omitempty"`
+	TermMonths *int    `json:"term_months,omitempty"`
+	// Cancel отменяет подписку: последним оплачиваемым днём становится день перед месяцем изменения.
+	Cancel *Cancel `json:"cancel,omitempty"`
+}
+
+type Cancel struct {
+	Reason  string  `json:"reason"`
+	Comment *string `json:"comment,omitempty"`
+}
+
+// Applied — событие subscription.change_applied.
+type Applied struct {
+	ID             string    `json:"id"`
+	SubscriptionID string    `json:"subscription_id"`
+	EffectiveMonth time.Time `json:"effective_month"`
+	Changes        Fields    `json:"changes"`
+	Previous       Fields    `json:"previous"`
+}
+
+// Job применяет изменения, месяц которых наступил, в порядке месяцев и создания.
+// Каждое изменение применяется в своей транзакции целиком или не применяется вовсе;
+// изменение, которое нарушает ограничения подписки или таблицу переходов статусов,
+// получает статус failed с текстом ошибки и больше не повторяется.
+type Job struct {
+	db *pgxpool.Pool
+}
+
+func NewJob(db *pgxpool.Pool) *Job {
+	return &Job{db: db}
+}
+
+func (j *Job) Name() string {
+	return "scheduled_changes"
+}
+
+func (j *Job) Run(ctx context.Context) error {
+	query := `
+		SELECT id FROM scheduled_changes
+		WHERE status = 'pending' AND effective_month <= CURRENT_DATE
+		ORDER BY effective_month, created_at
+	`
+	rows, err := j.db.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		err := storage.InTx(ctx, j.db, func(tx pgx.Tx) error {
+			return apply(ctx, tx, id)
+		})
+		// ErrNoRows — изменение уже применено, отменено или удалено вместе с подпиской.
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func apply(ctx context.Context, tx pgx.Tx, id string) error {
+	c := Applied{ID: id}
+	query := `SELECT subscription_id, effective_month, changes FROM scheduled_changes WHERE id = $1 AND status = 'pending' FOR UPDATE`
+	if err := tx.QueryRow(ctx, query, id).Scan(&c.SubscriptionID, &c.EffectiveMonth, &c.Changes); err != nil {
+		return err
+	}
+
+	// Изменение выполняется в точке сохранения, чтобы при ошибке отметить его failed в той же транзакции.
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	c.Previous, err = applyFields(ctx, sp, c)
+	if err == nil {
+		err = sp.Commit(ctx)
+	} else {
+		sp.Rollback(ctx)
+	}
+	if rejected(err) {
+		query = `UPDATE scheduled_changes SET status = 'failed', error = $2, applied_at = now() WHERE id = $1`
+		_, err = tx.Exec(ctx, query, id, err.Error())
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	query = `UPDATE scheduled_changes SET status = 'applied', previous = $2, applied_at = now() WHERE id = $1`
+	if _, err := tx.Exec(ctx, query, id, c.Previous); err != nil {
+		return err
+	}
+	return outbox.Write(ctx, tx, events.ChangeApplied, c)
+}
+
+// applyFields меняет поля подписки и возвращает их прежние значения.
+func applyFields(ctx context.Context, tx pgx.Tx, c Applied) (Fields, error) {
+	var (
+		prev      Fields
+		cur       Fields
+		start     time.Time
+		end       *time.Time
+		cancelled bool
+	)
+	query := `
+		SELECT price, start_date, end_date, auto_renew, term_months, cancelled_at IS NOT NULL
+		FROM subscriptions WHERE id = $1 FOR UPDATE
+	`
+	err := tx.QueryRow(ctx, query, c.SubscriptionID).Scan(&cur.Price, &start, &end, &cur.AutoRenew, &cur.TermMonths, &cancelled)
+	if err != nil {
+		return prev, err
+	}
+	if end != nil {
+		s := end.Format(DateLayout)
+		cur.EndDate = &s
+	}
+
+	f := c.Changes
+	if f.Price != nil {
+		prev.Price = cur.Price
+	}
+	if f.EndDate != nil {
+		prev.EndDate = cur.EndDate
+	}
+	if f.AutoRenew != nil {
+		prev.AutoRenew = cur.AutoRenew
+	}
+	if f.TermMonths != nil {
+		prev.TermMonths = cur.TermMonths
+	}
+
+	query = `
+		UPDATE subscriptions
+		SET price = COALESCE($2, price),
+		    end_date = COALESCE($3::date, end_date),
+		    auto_renew = COALESCE($4, auto_renew),
+		    term_months = COALESCE($5, term_months)
+		WHERE id = $1
+		RETURNING end_date
+	`
+	if err := tx.QueryRow(ctx, query, c.SubscriptionID, f.Price, f.EndDate, f.AutoRenew, f.TermMonths).Scan(&end); err != nil {
+		return prev, err
+	}
+
+	// Новая цена действует с effective_month: прежние месяцы считаются по previous_price.
+	// Повторная смена в том же месяце сохраняет цену, действовавшую до него.
+	if f.Price != nil && *f.Price != *cur.Price {
+		query := `
+			INSERT INTO subscription_price_changes (subscription_id, effective_month, price, previous_price, change_id)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (subscription_id, effective_month) DO UPDATE
+			SET price = EXCLUDED.price, change_id = EXCLUDED.change_id
+		`
+		if _, err := tx.Exec(ctx, query, c.SubscriptionID, c.EffectiveMonth, *f.Price, *cur.Price, c.ID); err != nil {
+			return prev, err
+		}
+	}
+
+	if f.Cancel == nil {
+		_, err := lifecycle.Sync(ctx, tx, c.SubscriptionID)
+		return prev, err
+	}
+
+	if cancelled {
+		return prev, lifecycle.ErrAlreadyCancelled
+	}
+	last := c.EffectiveMonth.AddDate(0, 0, -1)
+	if last.Before(start) {
+		return prev, lifecycle.ErrNotStarted
+	}
+	if end != nil && end.Before(last) {
+		last = *end
+	}
+	prev.EndDate = cur.EndDate
+	return prev, lifecycle.Cancel(ctx, tx, c.SubscriptionID, last, f.Cancel.Reason, f.Cancel.Comment)
+}
+
+// rejected сообщает, что изменение нельзя применить к текущему состоянию подписки.
+func rejected(err error) bool {
+	var terr *lifecycle.TransitionError
+	switch {
+	case errors.As(err, &terr), errors.Is(err, lifecycle.ErrAlreadyCancelled), errors.Is(err, lifecycle.ErrNotStarted):
+		return true
+	}
+	switch storage.Classify(err) {
+	case storage.ClassCheckViolation, storage.ClassInvalidInput:
+		return true
+	}
+	return false
+}
@@ -1,16 +1,61 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
+
+	"SubServices/internal/billing"
 )
 
 type Config struct {
 	Env         string           `yaml:"env" envDefault:"localhost"`
 	StoragePath string           `yaml:"storage_path" env-required:"true"`
 	HttpServer  HttpServerConfig `yaml:"http_server"`
+	Budgets     BudgetsConfig    `yaml:"budgets"`
+	Billing     BillingConfig    `yaml:"billing"`
+	Scheduler   SchedulerConfig  `yaml:"scheduler"`
+	Webhooks    WebhooksConfig   `yaml:"webhooks"`
+	Outbox      OutboxConfig     `yaml:"outbox"`
+	Stream      StreamConfig     `yaml:"stream"`
+	Listener    ListenerConfig   `yaml:"listener"`
+	Users       UsersConfig      `yaml:"users"`
+	Auth        AuthConfig       `yaml:"auth"`
+	RateLimit   RateLimitConfig  `yaml:"rate_limit"`
+}
+
+// redacted подставляется в логи вместо заданных секретов.
+const redacted = "[REDACTED]"
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+// LogValue раскрывает конфигурацию по секциям, чтобы секции с секретами
+// (auth, scheduler.reminders.smtp) логировались через собственный LogValue.
+func (c Config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("env", c.Env),
+		slog.String("storage_path", c.StoragePath),
+		slog.Any("http_server", c.HttpServer),
+		slog.Any("budgets", c.Budgets),
+		slog.Any("billing", c.Billing),
+		slog.Any("scheduler", c.Scheduler),
+		slog.Any("webhooks", c.Webhooks),
+		slog.Any("outbox", c.Outbox),
+		slog.Any("stream", c.Stream),
+		slog.Any("listener", c.Listener),
+		slog.Any("users", c.Users),
+		slog.Any("auth", c.Auth),
+		slog.Any("rate_limit", c.RateLimit),
+	)
 }
 
 type HttpServerConfig struct {
@@ -19,12 +64,251 @@ type HttpServerConfig struct {
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
 }
 
+const (
+	BudgetPolicyWarn   = "warn"
+	BudgetPolicyReject = "reject"
+)
+
+// BudgetsConfig задаёт реакцию на превышение бюджета пользователя:
+// "warn" — подписка сохраняется, нарушения возвращаются в ответе,
+// "reject" — запрос отклоняется.
+type BudgetsConfig struct {
+	Policy string `yaml:"policy" env-default:"warn"`
+}
+
+// BillingConfig задаёт режим расчёта стоимости неполных месяцев по умолчанию:
+// "none", "daily" или "half_month". Запрос может переопределить его параметром proration.
+type BillingConfig struct {
+	Proration string `yaml:"proration" env-default:"none"`
+}
+
+type SchedulerConfig struct {
+	Enabled   bool            `yaml:"enabled" env-default:"true"`
+	Interval  time.Duration   `yaml:"interval" env-default:"1h"`
+	Reminders RemindersConfig `yaml:"reminders"`
+	Renewals  RenewalsConfig  `yaml:"renewals"`
+}
+
+func (c SchedulerConfig) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Bool("enabled", c.Enabled),
+		slog.Duration("interval", c.Interval),
+		slog.Any("reminders", c.Reminders),
+		slog.Any("renewals", c.Renewals),
+	)
+}
+
+const (
+	RenewalModeExtend    = "extend"
+	RenewalModeSuccessor = "successor"
+)
+
+// RenewalsConfig задаёт способ продления подписок с auto_renew после окончания срока:
+// "extend" — end_date сдвигается на term_months, "successor" — создаётся новая подписка
+// на следующий срок со ссылкой на предыдущую.
+type RenewalsConfig struct {
+	Mode string `yaml:"mode" env-default:"extend"`
+}
+
+// RemindersConfig задаёт окно, в пределах которого о скором окончании подписки
+// отправляется напоминание, за сколько дней предупреждать об окончании пробного
+// периода и получателей напоминаний.
+type RemindersConfig struct {
+	Window          time.Duration     `yaml:"window" env-default:"720h"`
+	TrialEndingDays int               `yaml:"trial_ending_days" env-default:"3"`
+	Log             bool              `yaml:"log" env-default:"true"`
+	Webhook         WebhookSinkConfig `yaml:"webhook"`
+	SMTP            SMTPSinkConfig    `yaml:"smtp"`
+}
+
+func (c RemindersConfig) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Duration("window", c.Window),
+		slog.Int("trial_ending_days", c.TrialEndingDays),
+		slog.Bool("log", c.Log),
+		slog.Any("webhook", c.Webhook),
+		slog.Any("smtp", c.SMTP),
+	)
+}
+
+type WebhookSinkConfig struct {
+	URL     string        `yaml:"url"`
+	Timeout time.Duration `yaml:"timeout" env-default:"5s"`
+}
+
+type SMTPSinkConfig struct {
+	Addr     string   `yaml:"addr"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+// LogValue скрывает пароль SMTP.
+func (c SMTPSinkConfig) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("addr", c.Addr),
+		slog.String("username", c.Username),
+		slog.String("password", redact(c.Password)),
+		slog.String("from", c.From),
+		slog.Any("to", c.To),
+	)
+}
+
+// WebhooksConfig задаёт параметры доставки исходящих webhook'ов.
+// Задержка перед n-й повторной попыткой — backoff_base * 2^(n-1), но не более backoff_max.
+type WebhooksConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"2s"`
+	Timeout      time.Duration `yaml:"timeout" env-default:"10s"`
+	MaxAttempts  int           `yaml:"max_attempts" env-default:"8"`
+	BackoffBase  time.Duration `yaml:"backoff_base" env-default:"10s"`
+	BackoffMax   time.Duration `yaml:"backoff_max" env-default:"1h"`
+}
+
+// OutboxConfig задаёт частоту вычитывания outbox и срок хранения обработанных записей.
+type OutboxConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"1s"`
+	BatchSize    int           `yaml:"batch_size" env-default:"100"`
+	Retention    time.Duration `yaml:"retention" env-default:"168h"`
+}
+
+// StreamConfig задаёт параметры SSE-потока изменений: частоту heartbeat и
+// размер журнала событий, доступных для возобновления по Last-Event-ID.
+type StreamConfig struct {
+	Heartbeat time.Duration `yaml:"heartbeat" env-default:"15s"`
+	LogSize   int           `yaml:"log_size" env-default:"1000"`
+}
+
+// ListenerConfig задаёт задержку переподключения LISTEN-соединения: она
+// удваивается после каждой неудачной попытки, начиная с min и не более max.
+type ListenerConfig struct {
+	ReconnectMinDelay time.Duration `yaml:"reconnect_min_delay" env-default:"1s"`
+	ReconnectMaxDelay time.Duration `yaml:"reconnect_max_delay" env-default:"30s"`
+}
+
+const (
+	UserDeletePolicyReject  = "reject"
+	UserDeletePolicyCascade = "cascade"
+)
+
+// UsersConfig задаёт поведение при удалении пользователя, у которого есть подписки:
+// "reject" — удаление отклоняется, "cascade" — подписки удаляются вместе с пользователем.
+type UsersConfig struct {
+	DeletePolicy string `yaml:"delete_policy" env-default:"reject"`
+}
+
+// AuthConfig задаёт проверку JWT в заголовке Authorization. Токены подписываются
+// HS256 общим секретом hs256_secret или RS256/ES256 ключом из JWKS-файла jwks_file
+// (ключ выбирается по kid). Роль вызывающего определяется по claim role_claim:
+// admin_role — администратор, manager_role — руководитель команды, иначе — пользователь.
+type AuthConfig struct {
+	Enabled     bool          `yaml:"enabled" env-default:"false"`
+	HS256Secret string        `yaml:"hs256_secret" env:"AUTH_HS256_SECRET"`
+	JWKSFile    string        `yaml:"jwks_file"`
+	Issuer      string        `yaml:"issuer"`
+	Audience    string        `yaml:"audience"`
+	RoleClaim   string        `yaml:"role_claim" env-default:"role"`
+	AdminRole   string        `yaml:"admin_role" env-default:"admin"`
+	ManagerRole string        `yaml:"manager_role" env-default:"manager"`
+	Leeway      time.Duration `yaml:"leeway" env-default:"30s"`
+}
+
+// LogValue скрывает секрет HS256.
+func (c AuthConfig) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Bool("enabled", c.Enabled),
+		slog.String("hs256_secret", redact(c.HS256Secret)),
+		slog.String("jwks_file", c.JWKSFile),
+		slog.String("issuer", c.Issuer),
+		slog.String("audience", c.Audience),
+		slog.String("role_claim", c.RoleClaim),
+		slog.String("admin_role", c.AdminRole),
+		slog.String("manager_role", c.ManagerRole),
+		slog.Duration("leeway", c.Leeway),
+	)
+}
+
+// RateLimitConfig задаёт token bucket для каждого клиента: rate токенов в секунду,
+// не более burst запросов подряд. В routes можно задать отдельный лимит для маршрута
+// в виде "GET /api/subscriptions/{id}"; такие маршруты расходуют свою корзину.
+type RateLimitConfig struct {
+	Enabled bool                  `yaml:"enabled"`
+	Rate    float64               `yaml:"rate" env-default:"20"`
+	Burst   int                   `yaml:"burst" env-default:"40"`
+	Routes  map[string]RouteLimit `yaml:"routes"`
+}
+
+type RouteLimit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
 func MustLoadConfig(configPath string) (*Config, error) {
-	var cfg Config
+	// cleanenv подставляет env-default вместо нулевых значений, поэтому явный
+	// rate_limit.enabled: false превратился бы в true. Значение по умолчанию
+	// задаётся до чтения файла, а YAML его перезаписывает.
+	cfg := Config{RateLimit: RateLimitConfig{Enabled: true}}
 
 	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
 		log.Printf("Failed to read config file: %v", err)
 		return nil, err
 	}
+
+	switch cfg.Budgets.Policy {
+	case BudgetPolicyWarn, BudgetPolicyReject:
+	default:
+		return nil, fmt.Errorf("unknown budgets.policy %q", cfg.Budgets.Policy)
+	}
+
+	if _, err := billing.ParseProration(cfg.Billing.Proration); err != nil {
+		return nil, fmt.Errorf("billing.proration: %w", err)
+	}
+
+	switch cfg.Scheduler.Renewals.Mode {
+	case RenewalModeExtend, RenewalModeSuccessor:
+	default:
+		return nil, fmt.Errorf("unknown scheduler.renewals.mode %q", cfg.Scheduler.Renewals.Mode)
+	}
+
+	intervals := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"scheduler.interval", cfg.Scheduler.Interval},
+		{"webhooks.poll_interval", cfg.Webhooks.PollInterval},
+		{"outbox.poll_interval", cfg.Outbox.PollInterval},
+		{"stream.heartbeat", cfg.Stream.Heartbeat},
+		{"listener.reconnect_min_delay", cfg.Listener.ReconnectMinDelay},
+	}
+	for _, iv := range intervals {
+		if iv.value <= 0 {
+			return nil, fmt.Errorf("%s must be positive", iv.name)
+		}
+	}
+	if cfg.Listener.ReconnectMaxDelay < cfg.Listener.ReconnectMinDelay {
+		return nil, fmt.Errorf("listener.reconnect_max_delay must not be less than reconnect_min_delay")
+	}
+
+	switch cfg.Users.DeletePolicy {
+	case UserDeletePolicyReject, UserDeletePolicyCascade:
+	default:
+		return nil, fmt.Errorf("unknown users.delete_policy %q", cfg.Users.DeletePolicy)
+	}
+
+	// Лимиты проверяются, только если ограничение включено: выключенный limiter их не читает.
+	if cfg.RateLimit.Enabled {
+		if cfg.RateLimit.Rate <= 0 {
+			return nil, fmt.Errorf("rate_limit.rate must be positive")
+		}
+		for route, rl := range cfg.RateLimit.Routes {
+			if _, path, ok := strings.Cut(route, " "); !ok || !strings.HasPrefix(strings.TrimSpace(path), "/") {
+				return nil, fmt.Errorf("rate_limit.routes: invalid route %q, expected \"METHOD /path\"", route)
+			}
+			if rl.Rate <= 0 {
+				return nil, fmt.Errorf("rate_limit.routes[%q].rate must be positive", route)
+			}
+		}
+	}
+
 	return &cfg, nil
 }
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func loadYAML(t *testing.T, yaml string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("storage_path: postgres://localhost/db\n"+yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return MustLoadConfig(path)
+}
+
+func TestRateLimitValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+	}{
+		{name: "defaults", yaml: "", wantErr: false},
+		{name: "disabled without rate", yaml: "rate_limit:\n  enabled: false\n  rate: 0\n", wantErr: false},
+		{name: "disabled with invalid route", yaml: "rate_limit:\n  enabled: false\n  routes:\n    \"bad\": {rate: 0}\n", wantErr: false},
+		{name: "disabled with negative rate", yaml: "rate_limit:\n  enabled: false\n  rate: -1\n", wantErr: false},
+		{name: "enabled with negative rate", yaml: "rate_limit:\n  enabled: true\n  rate: -1\n", wantErr: true},
+		{name: "enabled with invalid route", yaml: "rate_limit:\n  enabled: true\n  routes:\n    \"bad\": {rate: 1}\n", wantErr: true},
+		{name: "enabled with zero route rate", yaml: "rate_limit:\n  enabled: true\n  routes:\n    \"GET /api/users\": {rate: 0}\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadYAML(t, tt.yaml)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MustLoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRateLimitEnabled(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want bool
+	}{
+		{name: "default", yaml: "", want: true},
+		{name: "section without enabled", yaml: "rate_limit:\n  rate: 5\n", want: true},
+		{name: "explicitly disabled", yaml: "rate_limit:\n  enabled: false\n", want: false},
+		{name: "explicitly enabled", yaml: "rate_limit:\n  enabled: true\n", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadYAML(t, tt.yaml)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.RateLimit.Enabled != tt.want {
+				t.Fatalf("rate_limit.enabled = %v, want %v", cfg.RateLimit.Enabled, tt.want)
+			}
+		})
+	}
+}
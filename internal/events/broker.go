@@ -0,0 +1,110 @@
+package events
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Entry — событие с порядковым номером в журнале брокера.
+type Entry struct {
+	Seq   uint64
+	Event Event
+	// ID — идентификатор для Last-Event-ID: эпоха брокера и Seq.
+	ID string
+}
+
+// Broker рассылает события подписчикам внутри процесса и хранит последние
+// события в ограниченном журнале, чтобы переподключившийся клиент мог
+// получить пропущенное. Медленный подписчик, не успевающий читать канал,
+// отключается, а не блокирует остальных.
+//
+// Журнал и нумерация у каждого процесса свои, поэтому ID событий начинаются
+// со случайной эпохи брокера: ID, выданный другим экземпляром или до перезапуска,
+// не совпадает по эпохе и приводит к reset, а не к повтору или пропуску чужих событий.
+type Broker struct {
+	epoch   string
+	mu      sync.Mutex
+	log     []Entry
+	size    int
+	seq     uint64
+	clients map[chan Entry]struct{}
+}
+
+func NewBroker(size int) *Broker {
+	return &Broker{epoch: uuid.New().String(), size: size, clients: make(map[chan Entry]struct{})}
+}
+
+func (b *Broker) Publish(_ context.Context, e Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	entry := Entry{Seq: b.seq, Event: e, ID: b.epoch + "-" + strconv.FormatUint(b.seq, 10)}
+
+	b.log = append(b.log, entry)
+	if len(b.log) > b.size {
+		b.log = b.log[len(b.log)-b.size:]
+	}
+
+	for ch := range b.clients {
+		select {
+		case ch <- entry:
+		default:
+			delete(b.clients, ch)
+			close(ch)
+		}
+	}
+	return nil
+}
+
+// Subscribe регистрирует подписчика. Если передан lastID, вместе с каналом
+// возвращаются события журнала после него; complete == false означает, что
+// часть событий уже вытеснена из журнала или lastID выдан другим брокером,
+// и клиенту нужно перечитать состояние.
+// Канал закрывается при вызове cancel или при отключении медленного подписчика.
+func (b *Broker) Subscribe(lastID string) (backlog []Entry, ch <-chan Entry, complete bool, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	complete = true
+	if lastID != "" {
+		lastSeq, ok := b.parseID(lastID)
+		// lastSeq больше текущего номера — ID не из журнала этого брокера.
+		complete = ok && (lastSeq == b.seq || (lastSeq < b.seq && len(b.log) > 0 && b.log[0].Seq <= lastSeq+1))
+		if complete {
+			for _, entry := range b.log {
+				if entry.Seq > lastSeq {
+					backlog = append(backlog, entry)
+				}
+			}
+		}
+	}
+
+	c := make(chan Entry, 64)
+	b.clients[c] = struct{}{}
+
+	cancel = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.clients[c]; ok {
+			delete(b.clients, c)
+			close(c)
+		}
+	}
+
+	return backlog, c, complete, cancel
+}
+
+// parseID возвращает номер события из ID, выданного этим брокером.
+func (b *Broker) parseID(id string) (uint64, bool) {
+	seq, ok := strings.CutPrefix(id, b.epoch+"-")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	return n, err == nil
+}
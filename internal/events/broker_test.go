@@ -0,0 +1,146 @@
+package events
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// publish публикует n событий и возвращает их ID по порядку.
+func publish(t *testing.T, b *Broker, n int) []string {
+	t.Helper()
+	ids := make([]string, 0, n)
+	for range n {
+		if err := b.Publish(context.Background(), Event{Type: SubscriptionUpdated}); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, b.log[len(b.log)-1].ID)
+	}
+	return ids
+}
+
+func seqs(entries []Entry) []uint64 {
+	result := make([]uint64, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, e.Seq)
+	}
+	return result
+}
+
+func TestSubscribeResume(t *testing.T) {
+	b := NewBroker(3)
+	ids := publish(t, b, 5) // в журнале остаются 3, 4 и 5
+
+	other := NewBroker(3)
+	otherIDs := publish(t, other, 5)
+
+	tests := []struct {
+		name         string
+		lastID       string
+		wantBacklog  []uint64
+		wantComplete bool
+	}{
+		{name: "no last id", lastID: "", wantBacklog: nil, wantComplete: true},
+		{name: "latest event", lastID: ids[4], wantBacklog: nil, wantComplete: true},
+		{name: "same epoch", lastID: ids[3], wantBacklog: []uint64{5}, wantComplete: true},
+		{name: "oldest in log", lastID: ids[2], wantBacklog: []uint64{4, 5}, wantComplete: true},
+		// Событие 2 вытеснено, но всё после него ещё в журнале.
+		{name: "just before the log", lastID: ids[1], wantBacklog: []uint64{3, 4, 5}, wantComplete: true},
+		{name: "older than the log", lastID: ids[0], wantBacklog: nil, wantComplete: false},
+		{name: "other broker with same seq", lastID: otherIDs[3], wantBacklog: nil, wantComplete: false},
+		{name: "ahead of this broker", lastID: b.epoch + "-6", wantBacklog: nil, wantComplete: false},
+		{name: "numeric id before epochs", lastID: "4", wantBacklog: nil, wantComplete: false},
+		{name: "epoch without seq", lastID: b.epoch + "-", wantBacklog: nil, wantComplete: false},
+		{name: "non-numeric seq", lastID: b.epoch + "-x", wantBacklog: nil, wantComplete: false},
+		{name: "negative seq", lastID: b.epoch + "--1", wantBacklog: nil, wantComplete: false},
+		{name: "garbage", lastID: "not-an-id", wantBacklog: nil, wantComplete: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backlog, _, complete, cancel := b.Subscribe(tt.lastID)
+			defer cancel()
+
+			if complete != tt.wantComplete {
+				t.Fatalf("Subscribe(%q) complete = %v, want %v", tt.lastID, complete, tt.wantComplete)
+			}
+			if got := seqs(backlog); !slices.Equal(got, tt.wantBacklog) {
+				t.Fatalf("Subscribe(%q) backlog = %v, want %v", tt.lastID, got, tt.wantBacklog)
+			}
+		})
+	}
+}
+
+func TestSubscribeAfterRestart(t *testing.T) {
+	// Новый брокер (перезапуск) не знает ID прежнего, даже если номера совпадают.
+	old := NewBroker(10)
+	ids := publish(t, old, 2)
+
+	restarted := NewBroker(10)
+	publish(t, restarted, 3)
+
+	backlog, _, complete, cancel := restarted.Subscribe(ids[1])
+	defer cancel()
+	if complete || len(backlog) > 0 {
+		t.Fatalf("Subscribe() = %v, complete %v; want full resync", seqs(backlog), complete)
+	}
+}
+
+func TestSubscribeEmptyBroker(t *testing.T) {
+	b := NewBroker(10)
+
+	// До первого события никакой ID этого брокера ещё не выдан.
+	_, _, complete, cancel := b.Subscribe(b.epoch + "-1")
+	defer cancel()
+	if complete {
+		t.Fatal("Subscribe() complete on empty broker, want resync")
+	}
+}
+
+func TestEntryID(t *testing.T) {
+	b := NewBroker(10)
+	ids := publish(t, b, 2)
+
+	for i, id := range ids {
+		if want := b.epoch + "-" + strconv.Itoa(i+1); id != want {
+			t.Fatalf("ID = %q, want %q", id, want)
+		}
+		if seq, ok := b.parseID(id); !ok || seq != uint64(i+1) {
+			t.Fatalf("parseID(%q) = %d, %v", id, seq, ok)
+		}
+	}
+}
+
+func TestLiveDelivery(t *testing.T) {
+	b := NewBroker(10)
+	_, ch, _, cancel := b.Subscribe("")
+
+	publish(t, b, 1)
+	if e := <-ch; e.Seq != 1 {
+		t.Fatalf("received seq %d, want 1", e.Seq)
+	}
+
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Fatal("channel still open after cancel")
+	}
+	cancel() // повторный вызов безопасен
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	b := NewBroker(10)
+	_, ch, _, cancel := b.Subscribe("")
+	defer cancel()
+
+	// Буфер канала — 64 события; следующее отключает подписчика.
+	publish(t, b, 65)
+
+	n := 0
+	for range ch {
+		n++
+	}
+	if n != 64 {
+		t.Fatalf("received %d events before disconnect, want 64", n)
+	}
+}
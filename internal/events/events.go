@@ -0,0 +1,73 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	SubscriptionCreated   = "subscription.created"
+	SubscriptionUpdated   = "subscription.updated"
+	SubscriptionDeleted   = "subscription.deleted"
+	SubscriptionEnding    = "subscription.ending"
+	TrialEnding           = "subscription.trial_ending"
+	SubscriptionPaused    = "subscription.paused"
+	SubscriptionResumed   = "subscription.resumed"
+	SubscriptionCancelled = "subscription.cancelled"
+	StatusChanged         = "subscription.status_changed"
+	SubscriptionRenewed   = "subscription.renewed"
+	ChangeApplied         = "subscription.change_applied"
+)
+
+// Types — все типы событий, на которые можно подписаться.
+var Types = []string{
+	SubscriptionCreated,
+	SubscriptionUpdated,
+	SubscriptionDeleted,
+	SubscriptionEnding,
+	TrialEnding,
+	SubscriptionPaused,
+	SubscriptionResumed,
+	SubscriptionCancelled,
+	StatusChanged,
+	SubscriptionRenewed,
+	ChangeApplied,
+}
+
+type Event struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Publisher доставляет события заинтересованным получателям.
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+func New(typ string, data any) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		ID:         uuid.New().String(),
+		Type:       typ,
+		OccurredAt: time.Now().UTC(),
+		Data:       raw,
+	}, nil
+}
+
+func IsKnownType(typ string) bool {
+	for _, t := range Types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,296 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"SubServices/internal/config"
+)
+
+var (
+	ErrMalformedToken = errors.New("malformed token")
+	ErrUnknownKey     = errors.New("unknown signing key")
+	ErrBadSignature   = errors.New("invalid signature")
+	ErrExpired        = errors.New("token expired")
+	ErrNoExpiry       = errors.New("token has no expiration")
+	ErrNotYetValid    = errors.New("token not yet valid")
+	ErrBadIssuer      = errors.New("invalid issuer")
+	ErrBadAudience    = errors.New("invalid audience")
+	ErrNoSubject      = errors.New("token has no subject")
+)
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Claims — зарегистрированные claims JWT и произвольные остальные.
+type Claims struct {
+	Subject   string         `json:"sub"`
+	Issuer    string         `json:"iss"`
+	Audience  audience       `json:"aud"`
+	ExpiresAt *int64         `json:"exp"`
+	NotBefore *int64         `json:"nbf"`
+	Raw       map[string]any `json:"-"`
+}
+
+// audience принимает aud и как строку, и как массив строк.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// Verifier проверяет подпись и claims JWT. Поддерживаются HS256 с общим секретом
+// и RS256/ES256 с открытыми ключами из локального JWKS-файла. Алгоритм токена
+// должен соответствовать типу ключа, alg=none не принимается.
+type Verifier struct {
+	hmacSecret []byte
+	rsaKeys    map[string]*rsa.PublicKey
+	ecKeys     map[string]*ecdsa.PublicKey
+	issuer     string
+	audience   string
+	leeway     time.Duration
+	now        func() time.Time
+}
+
+func NewVerifier(cfg config.AuthConfig) (*Verifier, error) {
+	v := &Verifier{
+		rsaKeys:  make(map[string]*rsa.PublicKey),
+		ecKeys:   make(map[string]*ecdsa.PublicKey),
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+		leeway:   cfg.Leeway,
+		now:      time.Now,
+	}
+	if cfg.HS256Secret != "" {
+		v.hmacSecret = []byte(cfg.HS256Secret)
+	}
+	if cfg.JWKSFile != "" {
+		if err := v.loadJWKS(cfg.JWKSFile); err != nil {
+			return nil, fmt.Errorf("load jwks: %w", err)
+		}
+	}
+	if v.hmacSecret == nil && len(v.rsaKeys) == 0 && len(v.ecKeys) == 0 {
+		return nil, errors.New("auth: neither hs256_secret nor jwks_file keys configured")
+	}
+	return v, nil
+}
+
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, ErrMalformedToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	if err := v.verifySignature(h, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformedToken
+	}
+	if err := decodeSegment(parts[1], &claims.Raw); err != nil {
+		return nil, ErrMalformedToken
+	}
+	if err := v.validateClaims(&claims); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+func (v *Verifier) verifySignature(h header, signed, sig []byte) error {
+	digest := sha256.Sum256(signed)
+
+	switch h.Alg {
+	case "HS256":
+		if v.hmacSecret == nil {
+			return ErrUnknownKey
+		}
+		mac := hmac.New(sha256.New, v.hmacSecret)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return ErrBadSignature
+		}
+		return nil
+
+	case "RS256":
+		key, ok := v.rsaKeys[h.Kid]
+		if !ok {
+			return ErrUnknownKey
+		}
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) != nil {
+			return ErrBadSignature
+		}
+		return nil
+
+	case "ES256":
+		key, ok := v.ecKeys[h.Kid]
+		if !ok {
+			return ErrUnknownKey
+		}
+		if len(sig) != 64 {
+			return ErrBadSignature
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(key, digest[:], r, s) {
+			return ErrBadSignature
+		}
+		return nil
+	}
+
+	return ErrUnknownKey
+}
+
+func (v *Verifier) validateClaims(c *Claims) error {
+	now := v.now()
+	// Токен без exp действовал бы бессрочно.
+	if c.ExpiresAt == nil {
+		return ErrNoExpiry
+	}
+	if now.After(time.Unix(*c.ExpiresAt, 0).Add(v.leeway)) {
+		return ErrExpired
+	}
+	if c.NotBefore != nil && now.Before(time.Unix(*c.NotBefore, 0).Add(-v.leeway)) {
+		return ErrNotYetValid
+	}
+	if v.issuer != "" && c.Issuer != v.issuer {
+		return ErrBadIssuer
+	}
+	if v.audience != "" {
+		found := false
+		for _, aud := range c.Audience {
+			if aud == v.audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ErrBadAudience
+		}
+	}
+	if c.Subject == "" {
+		return ErrNoSubject
+	}
+	return nil
+}
+
+// HasRole сообщает, содержит ли claim с именем claim строку role
+// (сам claim может быть строкой или массивом строк).
+func (c *Claims) HasRole(claim, role string) bool {
+	switch val := c.Raw[claim].(type) {
+	case string:
+		return val == role
+	case []any:
+		for _, item := range val {
+			if s, ok := item.(string); ok && s == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (v *Verifier) loadJWKS(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return err
+	}
+
+	for _, k := range set.Keys {
+		switch k.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(k.N)
+			if err != nil {
+				return fmt.Errorf("key %q: %w", k.Kid, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(k.E)
+			if err != nil {
+				return fmt.Errorf("key %q: %w", k.Kid, err)
+			}
+			v.rsaKeys[k.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+
+		case "EC":
+			if k.Crv != "P-256" {
+				return fmt.Errorf("key %q: unsupported curve %q", k.Kid, k.Crv)
+			}
+			x, err := base64.RawURLEncoding.DecodeString(k.X)
+			if err != nil {
+				return fmt.Errorf("key %q: %w", k.Kid, err)
+			}
+			y, err := base64.RawURLEncoding.DecodeString(k.Y)
+			if err != nil {
+				return fmt.Errorf("key %q: %w", k.Kid, err)
+			}
+			key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			if !key.Curve.IsOnCurve(key.X, key.Y) {
+				return fmt.Errorf("key %q: point is not on curve", k.Kid)
+			}
+			v.ecKeys[k.Kid] = key
+
+		default:
+			return fmt.Errorf("key %q: unsupported kty %q", k.Kid, k.Kty)
+		}
+	}
+	return nil
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+type testKeys struct {
+	secret []byte
+	rsa    *rsa.PrivateKey
+	ec     *ecdsa.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKeys{secret: []byte("test-secret"), rsa: rsaKey, ec: ecKey}
+}
+
+func (k testKeys) verifier() *Verifier {
+	return &Verifier{
+		hmacSecret: k.secret,
+		rsaKeys:    map[string]*rsa.PublicKey{"rsa-1": &k.rsa.PublicKey},
+		ecKeys:     map[string]*ecdsa.PublicKey{"ec-1": &k.ec.PublicKey},
+		issuer:     "https://issuer.example",
+		audience:   "subservices",
+		leeway:     30 * time.Second,
+		now:        func() time.Time { return testNow },
+	}
+}
+
+func segment(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// sign подписывает токен ключом алгоритма signAlg, указывая в заголовке h.
+func (k testKeys) sign(t *testing.T, h header, signAlg string, claims map[string]any) string {
+	t.Helper()
+	signed := segment(t, h) + "." + segment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch signAlg {
+	case "HS256":
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case "RS256":
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, k.ec, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	case "none":
+	default:
+		t.Fatalf("unknown alg %q", signAlg)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+		"iss": "https://issuer.example",
+		"aud": "subservices",
+		"exp": testNow.Add(time.Hour).Unix(),
+		"nbf": testNow.Add(-time.Hour).Unix(),
+	}
+}
+
+func with(key string, value any) func(map[string]any) {
+	return func(c map[string]any) {
+		if value == nil {
+			delete(c, key)
+			return
+		}
+		c[key] = value
+	}
+}
+
+func TestVerify(t *testing.T) {
+	keys := newTestKeys(t)
+	v := keys.verifier()
+
+	algs := []struct {
+		alg string
+		kid string
+	}{
+		{"HS256", ""},
+		{"RS256", "rsa-1"},
+		{"ES256", "ec-1"},
+	}
+
+	tests := []struct {
+		name   string
+		modify func(map[string]any)
+		want   error
+	}{
+		{name: "valid", want: nil},
+		{name: "audience array", modify: with("aud", []string{"other", "subservices"}), want: nil},
+		{name: "expired", modify: with("exp", testNow.Add(-time.Minute).Unix()), want: ErrExpired},
+		{name: "expired within leeway", modify: with("exp", testNow.Add(-10*time.Second).Unix()), want: nil},
+		{name: "missing exp", modify: with("exp", nil), want: ErrNoExpiry},
+		{name: "not yet valid", modify: with("nbf", testNow.Add(time.Minute).Unix()), want: ErrNotYetValid},
+		{name: "wrong issuer", modify: with("iss", "https://evil.example"), want: ErrBadIssuer},
+		{name: "wrong audience", modify: with("aud", "other"), want: ErrBadAudience},
+		{name: "missing audience", modify: with("aud", nil), want: ErrBadAudience},
+		{name: "missing subject", modify: with("sub", nil), want: ErrNoSubject},
+	}
+
+	for _, a := range algs {
+		for _, tt := range tests {
+			t.Run(a.alg+"/"+tt.name, func(t *testing.T) {
+				claims := validClaims()
+				if tt.modify != nil {
+					tt.modify(claims)
+				}
+				token := keys.sign(t, header{Alg: a.alg, Kid: a.kid}, a.alg, claims)
+
+				_, err := v.Verify(token)
+				if !errors.Is(err, tt.want) {
+					t.Fatalf("Verify() error = %v, want %v", err, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	keys := newTestKeys(t)
+	other := newTestKeys(t)
+	other.secret = []byte("other-secret")
+	v := keys.verifier()
+
+	tests := []struct {
+		name    string
+		header  header
+		signAlg string
+		signer  testKeys
+		want    error
+	}{
+		{name: "HS256 wrong secret", header: header{Alg: "HS256"}, signAlg: "HS256", signer: other, want: ErrBadSignature},
+		{name: "RS256 wrong key", header: header{Alg: "RS256", Kid: "rsa-1"}, signAlg: "RS256", signer: other, want: ErrBadSignature},
+		{name: "ES256 wrong key", header: header{Alg: "ES256", Kid: "ec-1"}, signAlg: "ES256", signer: other, want: ErrBadSignature},
+		{name: "RS256 unknown kid", header: header{Alg: "RS256", Kid: "rsa-2"}, signAlg: "RS256", signer: keys, want: ErrUnknownKey},
+		{name: "alg none", header: header{Alg: "none"}, signAlg: "none", signer: keys, want: ErrUnknownKey},
+		{name: "HS256 header with RS256 signature", header: header{Alg: "HS256", Kid: "rsa-1"}, signAlg: "RS256", signer: keys, want: ErrBadSignature},
+		{name: "RS256 header with ES256 key id", header: header{Alg: "RS256", Kid: "ec-1"}, signAlg: "ES256", signer: keys, want: ErrUnknownKey},
+		{name: "ES256 header with RS256 signature", header: header{Alg: "ES256", Kid: "ec-1"}, signAlg: "RS256", signer: keys, want: ErrBadSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := tt.signer.sign(t, tt.header, tt.signAlg, validClaims())
+
+			_, err := v.Verify(token)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyHS256WithoutSecret(t *testing.T) {
+	// Если настроены только ключи JWKS, токены HS256 не принимаются.
+	keys := newTestKeys(t)
+	v := keys.verifier()
+	v.hmacSecret = nil
+
+	token := keys.sign(t, header{Alg: "HS256"}, "HS256", validClaims())
+	if _, err := v.Verify(token); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Verify() error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	v := newTestKeys(t).verifier()
+
+	for _, token := range []string{"", "a.b", "a.b.c.d", "!!!.e30.", "e30.!!!.e30"} {
+		if _, err := v.Verify(token); err == nil {
+			t.Fatalf("Verify(%q) succeeded, want error", token)
+		}
+	}
+}
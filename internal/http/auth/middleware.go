@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"log/slog"
+
+	"SubServices/internal/apikeys"
+	"SubServices/internal/config"
+	"SubServices/internal/http/problem"
+)
+
+// Authenticator проверяет заголовок Authorization — "Bearer <jwt>" или
+// "ApiKey <key>" — и кладёт вызывающего в контекст запроса. Методы
+// nil-Authenticator пропускают запросы без проверки — так работает сервис
+// с выключенной аутентификацией.
+type Authenticator struct {
+	verifier    *Verifier
+	keys        *apikeys.Store
+	roleClaim   string
+	adminRole   string
+	managerRole string
+}
+
+// New создаёт Authenticator. JWT принимаются, только если задан hs256_secret
+// или jwks_file; API-ключи проверяются по keys.
+func New(cfg config.AuthConfig, keys *apikeys.Store) (*Authenticator, error) {
+	a := &Authenticator{
+		keys:        keys,
+		roleClaim:   cfg.RoleClaim,
+		adminRole:   cfg.AdminRole,
+		managerRole: cfg.ManagerRole,
+	}
+	if cfg.HS256Secret != "" || cfg.JWKSFile != "" {
+		v, err := NewVerifier(cfg)
+		if err != nil {
+			return nil, err
+		}
+		a.verifier = v
+	}
+	return a, nil
+}
+
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, credentials, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		credentials = strings.TrimSpace(credentials)
+
+		var (
+			p   *Principal
+			err error
+		)
+		switch {
+		case credentials == "":
+			err = errors.New("missing credentials")
+		case strings.EqualFold(scheme, "Bearer") && a.verifier != nil:
+			p, err = a.bearer(credentials)
+		case strings.EqualFold(scheme, "ApiKey"):
+			p, err = a.apiKey(r, credentials)
+		default:
+			err = errors.New("unsupported authorization scheme")
+		}
+		if err != nil {
+			slog.Debug("Rejected credentials", slog.String("scheme", scheme), slog.Any("error", err))
+			w.Header().Set("WWW-Authenticate", `Bearer, ApiKey`)
+			problem.Send(r.Context(), w, http.StatusUnauthorized, "missing or invalid credentials")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+	})
+}
+
+func (a *Authenticator) bearer(token string) (*Principal, error) {
+	claims, err := a.verifier.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+
+	role := RoleUser
+	switch {
+	case claims.HasRole(a.roleClaim, a.adminRole):
+		role = RoleAdmin
+	case claims.HasRole(a.roleClaim, a.managerRole):
+		role = RoleManager
+	}
+	return &Principal{Subject: claims.Subject, Role: role, Method: MethodJWT}, nil
+}
+
+// apiKey аутентифицирует межсервисный вызов. Ключ не привязан к пользователю,
+// поэтому видит данные всех пользователей в пределах своих scope.
+func (a *Authenticator) apiKey(r *http.Request, token string) (*Principal, error) {
+	k, err := a.keys.Authenticate(r.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{
+		Subject: k.ID,
+		Role:    RoleAdmin,
+		Method:  MethodAPIKey,
+		Scopes:  k.Scopes,
+	}, nil
+}
+
+// RequireScope пропускает вызывающих по API-ключу, только если у ключа есть scope.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if p, ok := FromContext(r.Context()); ok && !p.HasScope(scope) {
+				problem.Send(r.Context(), w, http.StatusForbidden, "api key lacks scope "+scope)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package auth
+
+import "context"
+
+type ctxKey struct{}
+
+const (
+	MethodJWT    = "jwt"
+	MethodAPIKey = "api_key"
+)
+
+const (
+	RoleAdmin   = "admin"
+	RoleManager = "manager"
+	RoleUser    = "user"
+)
+
+// Principal — аутентифицированный вызывающий. Что разрешено роли, решает
+// пакет policy. Scopes ограничивают только вызывающих по API-ключу:
+// пользователю с JWT доступны все маршруты своей роли.
+type Principal struct {
+	Subject string
+	Role    string
+	Method  string
+	Scopes  []string
+}
+
+// HasScope сообщает, разрешён ли вызывающему scope.
+func (p *Principal) HasScope(scope string) bool {
+	if p.Method != MethodAPIKey {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext возвращает вызывающего. ok == false, если аутентификация отключена.
+func FromContext(ctx context.Context) (p *Principal, ok bool) {
+	p, ok = ctx.Value(ctxKey{}).(*Principal)
+	return p, ok
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"SubServices/internal/apikeys"
+)
+
+func TestHasScope(t *testing.T) {
+	key := &Principal{Method: MethodAPIKey, Scopes: []string{apikeys.ScopeSubscriptionsRead}}
+	user := &Principal{Method: MethodJWT, Role: RoleUser}
+
+	tests := []struct {
+		name  string
+		p     *Principal
+		scope string
+		want  bool
+	}{
+		{"api key with scope", key, apikeys.ScopeSubscriptionsRead, true},
+		{"api key without scope", key, apikeys.ScopeSubscriptionsWrite, false},
+		{"api key without admin", key, apikeys.ScopeAdmin, false},
+		{"api key without scopes", &Principal{Method: MethodAPIKey}, apikeys.ScopeSubscriptionsRead, false},
+		// Scope ограничивают только API-ключи; права пользователя решает policy.
+		{"jwt ignores scopes", user, apikeys.ScopeAdmin, true},
+	}
+	for _, tt := range tests {
+		if got := tt.p.HasScope(tt.scope); got != tt.want {
+			t.Errorf("%s: HasScope(%q) = %v, want %v", tt.name, tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	h := RequireScope(apikeys.ScopeSubscriptionsWrite)(ok)
+
+	tests := []struct {
+		name string
+		p    *Principal
+		want int
+	}{
+		{"api key with scope", &Principal{Method: MethodAPIKey, Scopes: []string{apikeys.ScopeSubscriptionsWrite}}, http.StatusNoContent},
+		{"api key without scope", &Principal{Method: MethodAPIKey, Scopes: []string{apikeys.ScopeSubscriptionsRead}}, http.StatusForbidden},
+		{"jwt", &Principal{Method: MethodJWT, Role: RoleUser}, http.StatusNoContent},
+		{"authentication disabled", nil, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/subscriptions", nil)
+			if tt.p != nil {
+				r = r.WithContext(WithPrincipal(r.Context(), tt.p))
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"SubServices/internal/http/problem"
+	"SubServices/internal/policy"
+)
+
+// authorize сверяет действие вызывающего с политикой доступа.
+// Возвращает false, если ответ уже записан.
+func (h *Handler) authorize(ctx context.Context, w http.ResponseWriter, action policy.Action, ownerID string) bool {
+	err := h.Policy.Authorize(ctx, action, ownerID)
+	if errors.Is(err, policy.ErrForbidden) {
+		problem.Send(ctx, w, http.StatusForbidden, "forbidden")
+		return false
+	}
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return false
+	}
+	return true
+}
+
+// visibleUsers возвращает пользователей, которыми ограничивается выборка
+// (nil — без ограничения). Возвращает false, если ответ уже записан.
+func (h *Handler) visibleUsers(ctx context.Context, w http.ResponseWriter) ([]string, bool) {
+	ids, err := h.Policy.Visible(ctx)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return nil, false
+	}
+	return ids, true
+}
+
+// userIDParam разбирает {user_id} из пути и проверяет, что вызывающему
+// разрешено action над данными этого пользователя.
+func (h *Handler) userIDParam(w http.ResponseWriter, r *http.Request, action policy.Action) (string, bool) {
+	userID := chi.URLParam(r, "user_id")
+	if _, err := uuid.Parse(userID); err != nil {
+		problem.Validation(r.Context(), w, problem.Invalid("user_id", "must be a UUID"))
+		return "", false
+	}
+	if !h.authorize(r.Context(), w, action, userID) {
+		return "", false
+	}
+	return userID, true
+}
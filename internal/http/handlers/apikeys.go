@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"SubServices/internal/apikeys"
+	"SubServices/internal/http/problem"
+	"SubServices/internal/policy"
+)
+
+type APIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type APIKeyCreateResponse struct {
+	apikeys.Key
+	Token string `json:"key"`
+}
+
+// CreateAPIKey godoc
+// @Summary Выпустить API-ключ
+// @Description Создаёт ключ для межсервисного доступа со списком scope (subscriptions:read, subscriptions:write,
+// @Description reports:read, admin). Ключ передаётся в заголовке Authorization: ApiKey <key> и возвращается
+// @Description только в этом ответе — в базе хранится его хеш
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param key body APIKeyRequest true "Данные ключа"
+// @Success 201 {object} APIKeyCreateResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /api-keys [post]
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !h.authorize(ctx, w, policy.Manage, "") {
+		return
+	}
+
+	var req APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Send(ctx, w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 255 {
+		problem.Validation(ctx, w, problem.Invalid("name", "must be between 1 and 255 characters"))
+		return
+	}
+	if len(req.Scopes) == 0 {
+		problem.Validation(ctx, w, problem.Invalid("scopes", "must not be empty"))
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !apikeys.IsKnownScope(scope) {
+			problem.Validation(ctx, w, problem.Invalid("scopes", "unknown scope "+scope))
+			return
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		problem.Validation(ctx, w, problem.Invalid("expires_at", "must be in the future"))
+		return
+	}
+
+	k, token, err := apikeys.NewStore(h.DB).Create(ctx, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(APIKeyCreateResponse{Key: k, Token: token})
+}
+
+// ListAPIKeys godoc
+// @Summary Получить API-ключи
+// @Description Возвращает ключи без секретов, с временем последнего использования
+// @Tags api-keys
+// @Produce json
+// @Success 200 {array} apikeys.Key
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /api-keys [get]
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !h.authorize(ctx, w, policy.Manage, "") {
+		return
+	}
+
+	result, err := apikeys.NewStore(h.DB).List(ctx)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// RevokeAPIKey godoc
+// @Summary Отозвать API-ключ
+// @Tags api-keys
+// @Param id path string true "ID ключа"
+// @Success 204
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /api-keys/{id} [delete]
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !h.authorize(ctx, w, policy.Manage, "") {
+		return
+	}
+
+	if err := apikeys.NewStore(h.DB).Revoke(ctx, chi.URLParam(r, "id")); err != nil {
+		dbError(ctx, w, err, "API key not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,508 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"SubServices/internal/billing"
+	"SubServices/internal/catalog"
+	"SubServices/internal/config"
+	"SubServices/internal/http/problem"
+	"SubServices/internal/http/validate"
+	"SubServices/internal/month"
+	"SubServices/internal/policy"
+)
+
+// maxStatusMonths ограничивает диапазон отчётов: состояния бюджета и сводки по подпискам.
+const maxStatusMonths = 120
+
+type BudgetRequest struct {
+	ServiceName  *string `json:"service_name,omitempty"`
+	MonthlyLimit int     `json:"monthly_limit"`
+}
+
+type Budget struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"user_id"`
+	ServiceName  *string   `json:"service_name,omitempty"`
+	MonthlyLimit int       `json:"monthly_limit"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// BudgetViolation описывает месяц, в котором прогнозируемые траты превышают бюджет.
+// ServiceName пуст для общего бюджета пользователя.
+type BudgetViolation struct {
+	Month       month.Month `json:"month" swaggertype:"string" example:"07-2025"`
+	ServiceName *string     `json:"service_name,omitempty"`
+	Limit       int         `json:"limit"`
+	Projected   int         `json:"projected"`
+}
+
+// BudgetViolationResponse — problem+json с расширением budget_violations.
+type BudgetViolationResponse struct {
+	problem.Problem
+	BudgetViolations []BudgetViolation `json:"budget_violations"`
+}
+
+type ServiceBudgetStatus struct {
+	ServiceName string `json:"service_name"`
+	Actual      int    `json:"actual"`
+	Limit       int    `json:"limit"`
+	Exceeded    bool   `json:"exceeded"`
+}
+
+type BudgetStatus struct {
+	Month    month.Month           `json:"month" swaggertype:"string" example:"07-2025"`
+	Actual   int                   `json:"actual"`
+	Limit    *int                  `json:"limit,omitempty"`
+	Exceeded bool                  `json:"exceeded"`
+	Services []ServiceBudgetStatus `json:"services,omitempty"`
+}
+
+// CreateBudget godoc
+// @Summary Создать бюджет
+// @Description Создаёт месячный бюджет пользователя. Без service_name — общий лимит, с service_name — лимит на сервис.
+// @Description service_name сопоставляется с каталогом сервисов и сохраняется каноническим названием
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Param user_id path string true "ID пользователя"
+// @Param budget body BudgetRequest true "Данные бюджета"
+// @Success 201 {object} map[string]string
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /users/{user_id}/budgets [post]
+func (h *Handler) CreateBudget(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, ok := h.userIDParam(w, r, policy.Write)
+	if !ok {
+		return
+	}
+
+	var req BudgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Send(ctx, w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if req.MonthlyLimit < 0 {
+		problem.Validation(ctx, w, problem.Invalid("monthly_limit", "must not be negative"))
+		return
+	}
+	if !h.applyBudgetCatalog(ctx, w, &req) {
+		return
+	}
+
+	id := uuid.New().String()
+	query := `INSERT INTO budgets (id, user_id, service_name, monthly_limit) VALUES ($1, $2, $3, $4)`
+	_, err := h.DB.Exec(ctx, query, id, userID, req.ServiceName, req.MonthlyLimit)
+	if isUniqueViolation(err) {
+		problem.Send(ctx, w, http.StatusConflict, "Budget already exists")
+		return
+	}
+	if isForeignKeyViolation(err) {
+		problem.Send(ctx, w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"id": id})
+}
+
+// ListBudgets godoc
+// @Summary Получить бюджеты пользователя
+// @Tags budgets
+// @Produce json
+// @Param user_id path string true "ID пользователя"
+// @Success 200 {array} Budget
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /users/{user_id}/budgets [get]
+func (h *Handler) ListBudgets(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, ok := h.userIDParam(w, r, policy.Read)
+	if !ok {
+		return
+	}
+
+	budgets, err := h.loadBudgets(ctx, userID)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(budgets)
+}
+
+// GetBudget godoc
+// @Summary Получить бюджет
+// @Tags budgets
+// @Produce json
+// @Param user_id path string true "ID пользователя"
+// @Param id path string true "ID бюджета"
+// @Success 200 {object} Budget
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /users/{user_id}/budgets/{id} [get]
+func (h *Handler) GetBudget(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, ok := h.userIDParam(w, r, policy.Read)
+	if !ok {
+		return
+	}
+
+	query := `SELECT id, user_id, service_name, monthly_limit, created_at FROM budgets WHERE id = $1 AND user_id = $2`
+	row := h.DB.QueryRow(ctx, query, chi.URLParam(r, "id"), userID)
+
+	var b Budget
+	if err := row.Scan(&b.ID, &b.UserID, &b.ServiceName, &b.MonthlyLimit, &b.CreatedAt); err != nil {
+		dbError(ctx, w, err, "Budget not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(b)
+}
+
+// UpdateBudget godoc
+// @Summary Обновить бюджет
+// @Description service_name сопоставляется с каталогом сервисов так же, как при создании
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Param user_id path string true "ID пользователя"
+// @Param id path string true "ID бюджета"
+// @Param budget body BudgetRequest true "Данные бюджета"
+// @Success 200 {object} Budget
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /users/{user_id}/budgets/{id} [put]
+func (h *Handler) UpdateBudget(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, ok := h.userIDParam(w, r, policy.Write)
+	if !ok {
+		return
+	}
+
+	var req BudgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Send(ctx, w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if req.MonthlyLimit < 0 {
+		problem.Validation(ctx, w, problem.Invalid("monthly_limit", "must not be negative"))
+		return
+	}
+	if !h.applyBudgetCatalog(ctx, w, &req) {
+		return
+	}
+
+	query := `
+		UPDATE budgets
+		SET service_name=$1, monthly_limit=$2
+		WHERE id=$3 AND user_id=$4
+		RETURNING id, user_id, service_name, monthly_limit, created_at
+	`
+	row := h.DB.QueryRow(ctx, query, req.ServiceName, req.MonthlyLimit, chi.URLParam(r, "id"), userID)
+
+	var b Budget
+	err := row.Scan(&b.ID, &b.UserID, &b.ServiceName, &b.MonthlyLimit, &b.CreatedAt)
+	if isUniqueViolation(err) {
+		problem.Send(ctx, w, http.StatusConflict, "Budget already exists")
+		return
+	}
+	if err != nil {
+		dbError(ctx, w, err, "Budget not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(b)
+}
+
+// DeleteBudget godoc
+// @Summary Удалить бюджет
+// @Tags budgets
+// @Param user_id path string true "ID пользователя"
+// @Param id path string true "ID бюджета"
+// @Success 204
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /users/{user_id}/budgets/{id} [delete]
+func (h *Handler) DeleteBudget(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, ok := h.userIDParam(w, r, policy.Write)
+	if !ok {
+		return
+	}
+
+	query := `DELETE FROM budgets WHERE id = $1 AND user_id = $2`
+	tag, err := h.DB.Exec(ctx, query, chi.URLParam(r, "id"), userID)
+	if err != nil {
+		dbError(ctx, w, err, "Budget not found")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		problem.Send(ctx, w, http.StatusNotFound, "Budget not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetBudgetStatus godoc
+// @Summary Состояние бюджета
+// @Description Фактические траты пользователя по месяцам в сравнении с лимитами. По умолчанию — текущий месяц
+// @Tags budgets
+// @Produce json
+// @Param user_id path string true "ID пользователя"
+// @Param from query string false "Начало периода (MM-YYYY, YYYY-MM или YYYY-MM-DD)"
+// @Param to query string false "Конец периода (MM-YYYY, YYYY-MM или YYYY-MM-DD)"
+// @Param proration query string false "none, daily или half_month; по умолчанию billing.proration"
+// @Param date_format query string false "Формат дат ответа: mm-yyyy (по умолчанию), yyyy-mm, yyyy-mm-dd, rfc3339"
+// @Success 200 {array} BudgetStatus
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /users/{user_id}/budgets/status [get]
+func (h *Handler) GetBudgetStatus(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, ok := h.userIDParam(w, r, policy.Read)
+	if !ok {
+		return
+	}
+
+	var v validate.Validator
+	from, to := reportPeriod(&v, r.URL.Query())
+	mode := h.proration(&v, r.URL.Query())
+	if err := v.Err(); err != nil {
+		problem.Validation(ctx, w, err)
+		return
+	}
+
+	budgets, err := h.loadBudgets(ctx, userID)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	end := billing.MonthEnd(to)
+	items, err := h.loadBillingItems(ctx, userID, "", from, &end)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	format := month.FormatFrom(ctx)
+	months := billing.Months(from, to)
+	result := make([]BudgetStatus, 0, len(months))
+	for _, m := range months {
+		st := BudgetStatus{Month: month.FromTime(m).In(format), Actual: billing.Total(items, m, mode)}
+		for _, b := range budgets {
+			if b.ServiceName == nil {
+				limit := b.MonthlyLimit
+				st.Limit = &limit
+				st.Exceeded = st.Actual > limit
+				continue
+			}
+			actual := billing.Total(filterByService(items, *b.ServiceName), m, mode)
+			st.Services = append(st.Services, ServiceBudgetStatus{
+				ServiceName: *b.ServiceName,
+				Actual:      actual,
+				Limit:       b.MonthlyLimit,
+				Exceeded:    actual > b.MonthlyLimit,
+			})
+		}
+		result = append(result, st)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// enforceBudgets применяет budgets.policy к подписке s. Возвращает найденные нарушения
+// и false, если ответ уже записан и запрос нужно прервать.
+func (h *Handler) enforceBudgets(ctx context.Context, w http.ResponseWriter, s *Subscription) ([]BudgetViolation, bool) {
+	violations, err := h.checkBudgets(ctx, s)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return nil, false
+	}
+
+	if len(violations) > 0 && h.Cfg.Budgets.Policy == config.BudgetPolicyReject {
+		p := problem.New(ctx, http.StatusUnprocessableEntity, "budget exceeded")
+		p.Type = problem.TypeBudgetExceeded
+		problem.Write(w, p.Status, BudgetViolationResponse{Problem: p, BudgetViolations: violations})
+		return nil, false
+	}
+
+	return violations, true
+}
+
+// checkBudgets прогнозирует траты пользователя с учётом подписки s и возвращает
+// все месяцы, в которых будет превышен общий бюджет или бюджет на сервис.
+// Сумма растёт только в месяцы начала подписок, окончания пробных периодов и пауз, смены цены, поэтому
+// проверяются только они. Такой месяц может быть оплачен не полностью, и тогда
+// проверяется также следующий за ним.
+func (h *Handler) checkBudgets(ctx context.Context, s *Subscription) ([]BudgetViolation, error) {
+	budgets, err := h.loadBudgets(ctx, s.UserID)
+	if err != nil || len(budgets) == 0 {
+		return nil, err
+	}
+
+	candidate := s.billingItem()
+	others, err := h.loadBillingItems(ctx, s.UserID, s.ID, candidate.StartDate, candidate.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	items := append(others, candidate)
+	if err := h.loadPauses(ctx, items[len(items)-1:]); err != nil {
+		return nil, err
+	}
+	if err := h.loadPriceChanges(ctx, items[len(items)-1:]); err != nil {
+		return nil, err
+	}
+	mode := billing.Proration(h.Cfg.Billing.Proration)
+
+	var months []time.Time
+	seen := map[time.Time]bool{}
+	addMonth := func(m time.Time) {
+		if candidate.ActiveIn(m) && !seen[m] {
+			seen[m] = true
+			months = append(months, m)
+		}
+	}
+	for _, it := range items {
+		starts := []time.Time{it.StartDate}
+		if it.TrialEnd != nil {
+			starts = append(starts, *it.TrialEnd)
+		}
+		for _, p := range it.Pauses {
+			if p.Until != nil {
+				starts = append(starts, *p.Until)
+			}
+		}
+		for _, c := range it.PriceChanges {
+			starts = append(starts, c.From)
+		}
+		for _, t := range starts {
+			m := billing.MonthStart(t)
+			addMonth(m)
+			addMonth(m.AddDate(0, 1, 0))
+		}
+	}
+	slices.SortFunc(months, time.Time.Compare)
+
+	var violations []BudgetViolation
+	for _, b := range budgets {
+		scoped := items
+		if b.ServiceName != nil {
+			if catalog.Key(*b.ServiceName) != catalog.Key(s.ServiceName) {
+				continue
+			}
+			scoped = filterByService(items, *b.ServiceName)
+		}
+		for _, m := range months {
+			if projected := billing.Total(scoped, m, mode); projected > b.MonthlyLimit {
+				violations = append(violations, BudgetViolation{
+					Month:       month.FromTime(m).In(month.FormatFrom(ctx)),
+					ServiceName: b.ServiceName,
+					Limit:       b.MonthlyLimit,
+					Projected:   projected,
+				})
+			}
+		}
+	}
+
+	return violations, nil
+}
+
+func (h *Handler) loadBudgets(ctx context.Context, userID string) ([]Budget, error) {
+	query := `
+		SELECT id, user_id, service_name, monthly_limit, created_at
+		FROM budgets
+		WHERE user_id = $1
+		ORDER BY service_name NULLS FIRST
+	`
+	rows, err := h.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Budget, error) {
+		var b Budget
+		err := row.Scan(&b.ID, &b.UserID, &b.ServiceName, &b.MonthlyLimit, &b.CreatedAt)
+		return b, err
+	})
+}
+
+// loadBillingItems возвращает подписки пользователя, пересекающиеся с периодом [from, to],
+// вместе с их паузами и сменами цены, исключая подписку excludeID. to == nil означает открытый период.
+func (h *Handler) loadBillingItems(ctx context.Context, userID, excludeID string, from time.Time, to *time.Time) ([]billing.Item, error) {
+	query := `
+		SELECT id, service_name, price, start_date, end_date, trial_end, trial_price
+		FROM subscriptions
+		WHERE user_id = $1
+		AND ($2 = '' OR id::text <> $2)
+		AND (end_date IS NULL OR end_date >= $3)
+		AND ($4::date IS NULL OR start_date <= $4)
+	`
+	rows, err := h.DB.Query(ctx, query, userID, excludeID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	items, err := pgx.CollectRows(rows, scanBillingItem)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.loadPauses(ctx, items); err != nil {
+		return nil, err
+	}
+	return items, h.loadPriceChanges(ctx, items)
+}
+
+// filterByService оставляет подписки на сервис serviceName; названия сравниваются по catalog.Key.
+func filterByService(items []billing.Item, serviceName string) []billing.Item {
+	key := catalog.Key(serviceName)
+	var filtered []billing.Item
+	for _, it := range items {
+		if catalog.Key(it.ServiceName) == key {
+			filtered = append(filtered, it)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"SubServices/internal/billing"
+	"SubServices/internal/events"
+	"SubServices/internal/http/problem"
+	"SubServices/internal/http/validate"
+	"SubServices/internal/lifecycle"
+	"SubServices/internal/month"
+	"SubServices/internal/outbox"
+	"SubServices/internal/policy"
+	"SubServices/internal/storage"
+)
+
+// cancelReasons — допустимые коды причин отмены, см. chk_subscriptions_cancel_reason.
+var cancelReasons = []string{"too_expensive", "not_using", "switched_service", "technical_issues", "other"}
+
+const maxCancelCommentLen = 1000
+
+// Режимы вступления отмены в силу.
+const (
+	EffectiveImmediately       = "immediately"
+	EffectiveEndOfCurrentMonth = "end_of_current_month"
+	// effectiveAtPrefix предваряет месяц или день: at=09-2025, at=2025-09, at=2025-09-15.
+	effectiveAtPrefix = "at="
+)
+
+type CancelRequest struct {
+	Reason  string  `json:"reason" example:"too_expensive"`
+	Comment *string `json:"comment,omitempty"`
+	// Effective — immediately (по умолчанию), end_of_current_month или at=<дата>
+	// в формате MM-YYYY, YYYY-MM или YYYY-MM-DD.
+	Effective string `json:"effective,omitempty" example:"end_of_current_month"`
+}
+
+type Cancellation struct {
+	Reason      string    `json:"reason"`
+	Comment     *string   `json:"comment,omitempty"`
+	CancelledAt time.Time `json:"cancelled_at"`
+}
+
+type CancellationReason struct {
+	Reason string `json:"reason"`
+	Count  int    `json:"count"`
+}
+
+type ServiceCancellations struct {
+	ServiceName string               `json:"service_name"`
+	Total       int                  `json:"total"`
+	Reasons     []CancellationReason `json:"reasons"`
+}
+
+type CancellationReport struct {
+	From     month.Month            `json:"from" swaggertype:"string" example:"01-2025"`
+	To       month.Month            `json:"to" swaggertype:"string" example:"12-2025"`
+	Services []ServiceCancellations `json:"services"`
+}
+
+// CancelSubscription godoc
+// @Summary Отменить подписку
+// @Description Завершает подписку и сохраняет причину отмены. effective задаёт последний оплачиваемый день:
+// @Description immediately — сегодня, end_of_current_month — последний день текущего месяца,
+// @Description at=MM-YYYY или at=YYYY-MM — последний день указанного месяца, at=YYYY-MM-DD — указанный день.
+// @Description Дата в at= не может быть раньше сегодняшнего дня: закрытые месяцы задним числом не пересчитываются.
+// @Description Подписка, которая уже заканчивается раньше, не продлевается.
+// @Description reason: too_expensive, not_using, switched_service, technical_issues, other
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "ID подписки"
+// @Param cancel body CancelRequest true "Причина и дата отмены"
+// @Param date_format query string false "Формат дат ответа: mm-yyyy (по умолчанию), yyyy-mm, yyyy-mm-dd, rfc3339"
+// @Success 200 {object} Subscription
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /subscriptions/{id}/cancel [post]
+func (h *Handler) CancelSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var req CancelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Send(ctx, w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	var v validate.Validator
+	end := req.validate(&v)
+	if err := v.Err(); err != nil {
+		problem.Validation(ctx, w, err)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	var s Subscription
+	err := storage.InTx(ctx, h.DB, func(tx pgx.Tx) error {
+		locked, err := lockSubscription(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := h.Policy.Authorize(ctx, policy.Write, locked.UserID); err != nil {
+			return err
+		}
+		s, err = cancelSubscription(ctx, tx, locked, req, end)
+		return err
+	})
+	var terr *lifecycle.TransitionError
+	switch {
+	case errors.Is(err, policy.ErrForbidden):
+		problem.Send(ctx, w, http.StatusForbidden, "forbidden")
+		return
+	case errors.Is(err, lifecycle.ErrAlreadyCancelled), errors.Is(err, lifecycle.ErrNotStarted), errors.As(err, &terr):
+		problem.Send(ctx, w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		dbError(ctx, w, err, "Subscription not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.In(month.FormatFrom(ctx)))
+}
+
+// validate проверяет поля запроса и возвращает последний оплачиваемый день.
+func (req CancelRequest) validate(v *validate.Validator) month.Date {
+	if v.Required("reason", req.Reason) {
+		v.Check(slices.Contains(cancelReasons, req.Reason), "reason", "must be one of "+strings.Join(cancelReasons, ", "))
+	}
+	if req.Comment != nil {
+		v.MaxLen("comment", *req.Comment, maxCancelCommentLen)
+	}
+	now := time.Now()
+	end, ok := effectiveDate(req.Effective, now)
+	if v.Check(ok, "effective", "must be immediately, end_of_current_month or at=<date> in MM-YYYY, YYYY-MM or YYYY-MM-DD format") {
+		// Закрытые месяцы уже оплачены: отмена задним числом изменила бы их стоимость.
+		v.Check(!end.Before(month.DateFromTime(now)), "effective", "must not be in the past")
+	}
+	return end
+}
+
+// cancelSubscription отменяет подписку s, заблокированную в транзакции tx, с последним
+// оплачиваемым днём end и возвращает её новое состояние.
+func cancelSubscription(ctx context.Context, tx pgx.Tx, s Subscription, req CancelRequest, end month.Date) (Subscription, error) {
+	if s.Cancellation != nil {
+		return s, lifecycle.ErrAlreadyCancelled
+	}
+	if end.Before(s.StartDate) {
+		return s, lifecycle.ErrNotStarted
+	}
+	if s.EndDate != nil && s.EndDate.Before(end) {
+		end = *s.EndDate
+	}
+
+	if err := lifecycle.Cancel(ctx, tx, s.ID, end.Time(), req.Reason, req.Comment); err != nil {
+		return s, err
+	}
+	cancelled, err := lockSubscription(ctx, tx, s.ID)
+	if err != nil {
+		return s, err
+	}
+	return cancelled, outbox.Write(ctx, tx, events.SubscriptionCancelled, cancelled)
+}
+
+// effectiveDate возвращает последний оплачиваемый день для режима effective.
+func effectiveDate(effective string, now time.Time) (month.Date, bool) {
+	switch {
+	case effective == "" || effective == EffectiveImmediately:
+		return month.DateFromTime(now), true
+	case effective == EffectiveEndOfCurrentMonth:
+		return month.DateFromTime(billing.MonthEnd(now)), true
+	case strings.HasPrefix(effective, effectiveAtPrefix):
+		d, err := month.ParseEnd(strings.TrimPrefix(effective, effectiveAtPrefix))
+		return d, err == nil
+	}
+	return month.Date{}, false
+}
+
+// CancellationReport godoc
+// @Summary Причины отмены подписок
+// @Description Количество отмен по сервисам и причинам за период (по дате отмены). По умолчанию — текущий месяц
+// @Tags subscriptions
+// @Produce json
+// @Param from query string false "Начало периода (MM-YYYY, YYYY-MM или YYYY-MM-DD)"
+// @Param to query string false "Конец периода (MM-YYYY, YYYY-MM или YYYY-MM-DD)"
+// @Param user_id query string false "ID пользователя"
+// @Param service_name query string false "Название сервиса или его алиас; сравнивается без учёта регистра, пробелов и алфавита"
+// @Param date_format query string false "Формат дат ответа: mm-yyyy (по умолчанию), yyyy-mm, yyyy-mm-dd, rfc3339"
+// @Success 200 {object} CancellationReport
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /subscriptions/cancellations [get]
+func (h *Handler) CancellationReport(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	q := r.URL.Query()
+
+	var v validate.Validator
+	from, to := reportPeriod(&v, q)
+	var userID *string
+	if id := q.Get("user_id"); id != "" && v.UUID("user_id", id) {
+		userID = &id
+	}
+	if err := v.Err(); err != nil {
+		problem.Validation(ctx, w, err)
+		return
+	}
+
+	var visible []string
+	if userID != nil {
+		if !h.authorize(ctx, w, policy.Read, *userID) {
+			return
+		}
+	} else {
+		var ok bool
+		if visible, ok = h.visibleUsers(ctx, w); !ok {
+			return
+		}
+	}
+
+	services, err := h.serviceNames(ctx, q.Get("service_name"))
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	query := `
+		SELECT service_name, cancel_reason, count(*)
+		FROM subscriptions
+		WHERE cancelled_at IS NOT NULL
+		AND ($1::uuid IS NULL OR user_id = $1)
+		AND ($2::text[] IS NULL OR service_name = ANY($2))
+		AND ($3::uuid[] IS NULL OR user_id = ANY($3))
+		AND cancelled_at::date BETWEEN $4 AND $5
+		GROUP BY service_name, cancel_reason
+		ORDER BY service_name, count(*) DESC, cancel_reason
+	`
+	rows, err := h.DB.Query(ctx, query, userID, services, visible, from, billing.MonthEnd(to))
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	format := month.FormatFrom(ctx)
+	result := CancellationReport{
+		From:     month.FromTime(from).In(format),
+		To:       month.FromTime(to).In(format),
+		Services: []ServiceCancellations{},
+	}
+	var (
+		serviceName string
+		reason      CancellationReason
+	)
+	_, err = pgx.ForEachRow(rows, []any{&serviceName, &reason.Reason, &reason.Count}, func() error {
+		if n := len(result.Services); n == 0 || result.Services[n-1].ServiceName != serviceName {
+			result.Services = append(result.Services, ServiceCancellations{ServiceName: serviceName})
+		}
+		svc := &result.Services[len(result.Services)-1]
+		svc.Total += reason.Count
+		svc.Reasons = append(svc.Reasons, reason)
+		return nil
+	})
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
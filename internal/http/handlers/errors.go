@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"SubServices/internal/http/problem"
+	"SubServices/internal/storage"
+)
+
+// dbError отвечает на ошибку БД статусом по её классу: 400 для значений, которые
+// Postgres не принял, 409 для конфликтов, 503 для недоступной БД и неразрешённых
+// конфликтов транзакций. notFound — detail ответа 404, если запись не найдена.
+func dbError(ctx context.Context, w http.ResponseWriter, err error, notFound string) {
+	switch storage.Classify(err) {
+	case storage.ClassNotFound:
+		problem.Send(ctx, w, http.StatusNotFound, notFound)
+	case storage.ClassInvalidInput:
+		problem.Send(ctx, w, http.StatusBadRequest, "malformed identifier or value")
+	case storage.ClassCheckViolation:
+		problem.Send(ctx, w, http.StatusBadRequest, "value violates constraint "+storage.ConstraintName(err))
+	case storage.ClassUniqueViolation:
+		problem.Send(ctx, w, http.StatusConflict, "resource already exists")
+	case storage.ClassForeignKeyViolation:
+		problem.Send(ctx, w, http.StatusConflict, "operation conflicts with related resources")
+	case storage.ClassSerialization:
+		w.Header().Set("Retry-After", "1")
+		problem.Send(ctx, w, http.StatusServiceUnavailable, "concurrent modification, retry the request")
+	case storage.ClassUnavailable:
+		slog.Warn("Database unavailable", slog.Any("error", err))
+		w.Header().Set("Retry-After", "5")
+		problem.Send(ctx, w, http.StatusServiceUnavailable, "database unavailable")
+	default:
+		slog.Error("Database error", slog.Any("error", err))
+		problem.Send(ctx, w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+func isUniqueViolation(err error) bool {
+	return storage.Classify(err) == storage.ClassUniqueViolation
+}
+
+func isForeignKeyViolation(err error) bool {
+	return storage.Classify(err) == storage.ClassForeignKeyViolation
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+
+	"SubServices/internal/http/problem"
+	"SubServices/internal/month"
+)
+
+// DateFormatHeader — альтернатива параметру date_format для клиентов,
+// которым неудобно менять query-строку каждого запроса.
+const DateFormatHeader = "X-Date-Format"
+
+// DateFormat читает формат дат ответа из параметра date_format или заголовка
+// X-Date-Format (параметр важнее) и сохраняет его в контексте запроса.
+func DateFormat(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := r.URL.Query().Get("date_format")
+		if v == "" {
+			v = r.Header.Get(DateFormatHeader)
+		}
+		f, err := month.ParseFormat(v)
+		if err != nil {
+			problem.Validation(r.Context(), w, problem.Invalid("date_format", "must be one of mm-yyyy, yyyy-mm, yyyy-mm-dd, rfc3339"))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(month.WithFormat(r.Context(), f)))
+	})
+}
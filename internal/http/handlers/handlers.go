@@ -3,49 +3,141 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
-	"log/slog"
-
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"SubServices/internal/billing"
+	"SubServices/internal/config"
+	"SubServices/internal/events"
+	"SubServices/internal/http/problem"
+	"SubServices/internal/http/validate"
+	"SubServices/internal/lifecycle"
+	"SubServices/internal/month"
+	"SubServices/internal/outbox"
+	"SubServices/internal/policy"
+	"SubServices/internal/storage"
+)
+
+const (
+	// maxServiceNameLen соответствует subscriptions.service_name VARCHAR(255).
+	maxServiceNameLen = 255
+	// maxPrice отсекает опечатки вроде лишних нулей задолго до переполнения INTEGER.
+	maxPrice = 10_000_000
+	// maxTermMonths — самый длинный срок автопродления, 10 лет.
+	maxTermMonths = 120
+)
+
+// Допустимый диапазон start_date и end_date.
+var (
+	minDate = month.DateOf(2000, time.January, 1)
+	maxDate = month.DateOf(2100, time.December, 31)
 )
 
+const monthRangeMessage = "must be between 01-2000 and 12-2100"
+
 type Handler struct {
-	DB *pgxpool.Pool
+	DB     *pgxpool.Pool
+	Cfg    *config.Config
+	Broker *events.Broker
+	Policy *policy.Policy
 }
 
-func NewHandler(pool *pgxpool.Pool) *Handler {
-	return &Handler{DB: pool}
+func NewHandler(pool *pgxpool.Pool, cfg *config.Config, broker *events.Broker) *Handler {
+	return &Handler{DB: pool, Cfg: cfg, Broker: broker, Policy: policy.New(pool)}
 }
 
 type SubscriptionCreateRequest struct {
 	ServiceName string  `json:"service_name"`
-	Price       int     `json:"price"`
+	Price       *int    `json:"price,omitempty"`
 	UserID      string  `json:"user_id"`
 	StartDate   string  `json:"start_date"`
 	EndDate     *string `json:"end_date,omitempty"`
+	TrialEnd    *string `json:"trial_end,omitempty"`
+	TrialPrice  *int    `json:"trial_price,omitempty"`
+	AutoRenew   bool    `json:"auto_renew,omitempty"`
+	TermMonths  *int    `json:"term_months,omitempty"`
 }
 
 type SubscriptionUpdateRequest struct {
 	ServiceName string  `json:"service_name"`
-	Price       int     `json:"price"`
+	Price       *int    `json:"price,omitempty"`
 	UserID      string  `json:"user_id"`
 	StartDate   string  `json:"start_date"`
 	EndDate     *string `json:"end_date,omitempty"`
+	TrialEnd    *string `json:"trial_end,omitempty"`
+	TrialPrice  *int    `json:"trial_price,omitempty"`
+	AutoRenew   bool    `json:"auto_renew,omitempty"`
+	TermMonths  *int    `json:"term_months,omitempty"`
 }
 
 type Subscription struct {
-	ID          string     `json:"id"`
-	UserID      string     `json:"user_id"`
-	ServiceName string     `json:"service_name"`
-	Price       int        `json:"price"`
-	StartDate   time.Time  `json:"start_date"`
-	EndDate     *time.Time `json:"end_date,omitempty"`
+	ID          string      `json:"id"`
+	UserID      string      `json:"user_id"`
+	ServiceID   *string     `json:"service_id,omitempty"`
+	ServiceName string      `json:"service_name"`
+	Price       int         `json:"price"`
+	StartDate   month.Date  `json:"start_date" swaggertype:"string" example:"07-2025"`
+	EndDate     *month.Date `json:"end_date,omitempty" swaggertype:"string" example:"12-2025"`
+	// TrialEnd — последний день пробного периода; до него включительно действует TrialPrice
+	// (бесплатно, если TrialPrice не задан).
+	TrialEnd   *month.Date `json:"trial_end,omitempty" swaggertype:"string" example:"2025-07-14"`
+	TrialPrice *int        `json:"trial_price,omitempty"`
+	// AutoRenew — после end_date подписка продлевается на TermMonths месяцев.
+	AutoRenew  bool `json:"auto_renew"`
+	TermMonths *int `json:"term_months,omitempty"`
+	// RenewedFrom — подписка, продлением которой создана эта.
+	RenewedFrom *string `json:"renewed_from,omitempty"`
+	// Status пересчитывается при каждом изменении подписки и фоновой задачей по текущей дате.
+	Status lifecycle.Status `json:"status" swaggertype:"string" example:"active"`
+	// Tags — теги подписки в алфавитном порядке, см. /subscriptions/{id}/tags.
+	Tags []string `json:"tags"`
+	// Cancellation заполняется, если подписка отменена через /cancel.
+	Cancellation *Cancellation `json:"cancellation,omitempty"`
+}
+
+// In возвращает копию подписки, даты которой выводятся в формате f.
+func (s Subscription) In(f month.Format) Subscription {
+	s.StartDate = s.StartDate.In(f)
+	if s.EndDate != nil {
+		end := s.EndDate.AsEnd().In(f)
+		s.EndDate = &end
+	}
+	if s.TrialEnd != nil {
+		trialEnd := s.TrialEnd.AsEnd().In(f)
+		s.TrialEnd = &trialEnd
+	}
+	return s
+}
+
+func (s *Subscription) billingItem() billing.Item {
+	it := billing.Item{ID: s.ID, ServiceName: s.ServiceName, Price: s.Price, StartDate: s.StartDate.Time(), TrialPrice: s.TrialPrice}
+	if s.EndDate != nil {
+		end := s.EndDate.Time()
+		it.EndDate = &end
+	}
+	if s.TrialEnd != nil {
+		trialEnd := s.TrialEnd.Time()
+		it.TrialEnd = &trialEnd
+	}
+	return it
+}
+
+type SubscriptionCreateResponse struct {
+	ID               string            `json:"id"`
+	BudgetViolations []BudgetViolation `json:"budget_violations,omitempty"`
+}
+
+type SubscriptionResponse struct {
+	Subscription
+	BudgetViolations []BudgetViolation `json:"budget_violations,omitempty"`
 }
 
 func Health(w http.ResponseWriter, r *http.Request) {
@@ -55,14 +147,26 @@ func Health(w http.ResponseWriter, r *http.Request) {
 
 // CreateSubscription godoc
 // @Summary Создать подписку
-// @Description Создаёт новую подписку. start_date и end_date передаются в формате MM-YYYY
+// @Description Создаёт новую подписку. start_date и end_date передаются в формате MM-YYYY, YYYY-MM или YYYY-MM-DD.
+// @Description Ошибки всех полей возвращаются разом в массиве errors.
+// @Description trial_end — последний день пробного периода, до которого действует trial_price (без него — бесплатно).
+// @Description term_months — срок подписки; без end_date он отсчитывается от start_date. С auto_renew подписка
+// @Description продлевается на term_months после end_date (см. scheduler.renewals.mode).
+// @Description service_name сопоставляется с каталогом сервисов по названию и алиасам; если price не передан,
+// @Description берётся цена сервиса по умолчанию. user_id должен ссылаться на существующего пользователя.
+// @Description При превышении бюджета пользователя нарушения возвращаются в budget_violations
+// @Description либо запрос отклоняется с 422, в зависимости от budgets.policy
 // @Tags subscriptions
 // @Accept json
 // @Produce json
 // @Param subscription body SubscriptionCreateRequest true "Данные подписки"
-// @Success 201 {object} map[string]string
-// @Failure 400 {string} string
-// @Failure 500 {string} string
+// @Success 201 {object} SubscriptionCreateResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 422 {object} BudgetViolationResponse
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
 // @Router /subscriptions [post]
 func (h *Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
@@ -70,81 +174,224 @@ func (h *Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
 
 	var req SubscriptionCreateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid json", http.StatusBadRequest)
+		problem.Send(ctx, w, http.StatusBadRequest, "invalid json")
 		return
 	}
 
 	s, err := req.ToModel()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Validation(ctx, w, err)
+		return
+	}
+	if !h.authorize(ctx, w, policy.Write, s.UserID) {
 		return
 	}
 
-	query := `INSERT INTO subscriptions (id, user_id, service_name, price, start_date, end_date)
-		VALUES ($1, $2, $3, $4, $5, $6)`
-	_, err = h.DB.Exec(ctx, query, s.ID, s.UserID, s.ServiceName, s.Price, s.StartDate, s.EndDate)
-	if err != nil {
-		slog.Error("Failed to insert subscription",
-			slog.String("s.ID", s.ID),
-			slog.String("s.ServiceName", s.ServiceName),
-			slog.String("s.Price", strconv.Itoa(s.Price)),
-			slog.String("s.UserID", s.UserID),
+	if !h.applyCatalog(ctx, w, s, req.Price) {
+		return
+	}
 
-			slog.Any("s.EndDate", &s.EndDate),
-			slog.Any("error", err))
+	violations, ok := h.enforceBudgets(ctx, w, s)
+	if !ok {
+		return
+	}
 
-		http.Error(w, "Failed to insert subscription", http.StatusInternalServerError)
+	query := `INSERT INTO subscriptions (id, user_id, service_id, service_name, price, start_date, end_date, trial_end, trial_price, auto_renew, term_months)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	err = storage.InTx(ctx, h.DB, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, query, s.ID, s.UserID, s.ServiceID, s.ServiceName, s.Price, s.StartDate, s.EndDate, s.TrialEnd, s.TrialPrice, s.AutoRenew, s.TermMonths); err != nil {
+			return err
+		}
+		if s.Status, err = lifecycle.Init(ctx, tx, s.ID); err != nil {
+			return err
+		}
+		return outbox.Write(ctx, tx, events.SubscriptionCreated, s)
+	})
+	if isForeignKeyViolation(err) {
+		problem.Validation(ctx, w, problem.Invalid("user_id", "user does not exist"))
+		return
+	}
+	if err != nil {
+		dbError(ctx, w, err, "")
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"id": s.ID})
+	json.NewEncoder(w).Encode(SubscriptionCreateResponse{ID: s.ID, BudgetViolations: violations})
 }
 
 // GetSubscription godoc
 // @Summary Получить подписку
-// @Description Возвращает подписку по ID
+// @Description Возвращает подписку по ID. С as_of возвращается состояние подписки на конец этого дня (UTC)
+// @Description из истории изменений, в том числе если подписка позже удалена.
+// @Description История ведётся с установки миграции 018_subscriptions_history: для подписок, созданных раньше,
+// @Description as_of до этого момента возвращает их состояние на момент установки, а не фактическое
 // @Tags subscriptions
 // @Produce json
 // @Param id path string true "ID подписки"
+// @Param as_of query string false "Дата среза истории (YYYY-MM-DD)"
+// @Param date_format query string false "Формат дат ответа: mm-yyyy (по умолчанию), yyyy-mm, yyyy-mm-dd, rfc3339"
 // @Success 200 {object} Subscription
-// @Failure 404 {string} string
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
 // @Router /subscriptions/{id} [get]
 func (h *Handler) GetSubscription(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	id := chi.URLParam(r, "id")
-	query := `SELECT id, service_name, price, user_id, start_date, end_date FROM subscriptions WHERE id = $1`
-	row := h.DB.QueryRow(ctx, query, id)
+	asOf, err := parseAsOf(r.URL.Query().Get("as_of"))
+	if err != nil {
+		problem.Validation(ctx, w, err)
+		return
+	}
 
-	var s Subscription
-	err := row.Scan(&s.ID, &s.ServiceName, &s.Price, &s.UserID, &s.StartDate, &s.EndDate)
+	var s *Subscription
+	if asOf != nil {
+		s, err = h.loadSubscriptionAt(ctx, chi.URLParam(r, "id"), *asOf)
+	} else {
+		s, err = h.loadSubscription(ctx, chi.URLParam(r, "id"))
+	}
 	if err != nil {
-		http.Error(w, "Subscription not found", http.StatusNotFound)
+		dbError(ctx, w, err, "Subscription not found")
+		return
+	}
+	if !h.authorize(ctx, w, policy.Read, s.UserID) {
 		return
 	}
 
 	json.NewEncoder(w).Encode(s)
 }
 
+// parseAsOf разбирает параметр as_of; пустое значение означает текущее состояние.
+func parseAsOf(v string) (*month.Date, error) {
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return nil, problem.Invalid("as_of", "must be a date in YYYY-MM-DD format")
+	}
+	d := month.DateFromTime(t)
+	return &d, nil
+}
+
+// historyAt выбирает из subscriptions_history версии подписок, действовавшие в последнюю
+// микросекунду дня param (UTC). Результат содержит subscriptionFields и подставляется вместо
+// subscriptions; теги не версионируются и берутся текущие.
+func historyAt(param string) string {
+	moment := `(((` + param + `::date + 1)::timestamp AT TIME ZONE 'UTC') - interval '1 microsecond')`
+	return `(
+		SELECT ` + subscriptionFields + `
+		FROM subscriptions_history
+		WHERE valid_from <= ` + moment + ` AND (valid_to IS NULL OR valid_to > ` + moment + `)
+	)`
+}
+
+// loadSubscriptionAt загружает состояние подписки на дату asOf из истории изменений.
+func (h *Handler) loadSubscriptionAt(ctx context.Context, id string, asOf month.Date) (*Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM ` + historyAt("$2") + ` AS subscriptions WHERE id = $1`
+	rows, _ := h.DB.Query(ctx, query, id, asOf)
+
+	s, err := pgx.CollectExactlyOneRow(rows, scanSubscription)
+	if err != nil {
+		return nil, err
+	}
+	s = s.In(month.FormatFrom(ctx))
+	return &s, nil
+}
+
+// loadSubscription загружает подписку; даты выводятся в формате из контекста запроса.
+func (h *Handler) loadSubscription(ctx context.Context, id string) (*Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE id = $1`
+	rows, _ := h.DB.Query(ctx, query, id)
+
+	s, err := pgx.CollectExactlyOneRow(rows, scanSubscription)
+	if err != nil {
+		return nil, err
+	}
+	s = s.In(month.FormatFrom(ctx))
+	return &s, nil
+}
+
+// querySubscriptions выполняет запрос, выбирающий subscriptionColumns.
+func (h *Handler) querySubscriptions(ctx context.Context, query string, args ...any) ([]Subscription, error) {
+	rows, err := h.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	result, err := pgx.CollectRows(rows, scanSubscription)
+	if err != nil {
+		return nil, err
+	}
+	format := month.FormatFrom(ctx)
+	for i := range result {
+		result[i] = result[i].In(format)
+	}
+	return result, nil
+}
+
+// subscriptionFields — столбцы таблицы subscriptions, которые также хранит subscriptions_history.
+const subscriptionFields = `id, service_id, service_name, price, user_id, start_date, end_date, trial_end, trial_price,
+	cancel_reason, cancel_comment, cancelled_at, status, auto_renew, term_months, renewed_from`
+
+const subscriptionColumns = subscriptionFields + `, ` + tagsColumn
+
+func scanSubscription(row pgx.CollectableRow) (Subscription, error) {
+	var (
+		s           Subscription
+		reason      *string
+		comment     *string
+		cancelledAt *time.Time
+	)
+	err := row.Scan(&s.ID, &s.ServiceID, &s.ServiceName, &s.Price, &s.UserID, &s.StartDate, &s.EndDate, &s.TrialEnd, &s.TrialPrice,
+		&reason, &comment, &cancelledAt, &s.Status, &s.AutoRenew, &s.TermMonths, &s.RenewedFrom, &s.Tags)
+	if reason != nil && cancelledAt != nil {
+		s.Cancellation = &Cancellation{Reason: *reason, Comment: comment, CancelledAt: *cancelledAt}
+	}
+	return s, err
+}
+
 // DeleteSubscription godoc
 // @Summary Удалить подписку
 // @Description Удаляет подписку по ID
 // @Tags subscriptions
 // @Param id path string true "ID подписки"
 // @Success 204
-// @Failure 404 {string} string
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
 // @Router /subscriptions/{id} [delete]
 func (h *Handler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
 	id := chi.URLParam(r, "id")
-	query := `DELETE FROM subscriptions WHERE id = $1`
-	tag, err := h.DB.Exec(ctx, query, id)
-	if err != nil || tag.RowsAffected() == 0 {
-		http.Error(w, "Subscription not found", http.StatusNotFound)
+	query := `DELETE FROM subscriptions WHERE id = $1 RETURNING user_id, service_name`
+	err := storage.InTx(ctx, h.DB, func(tx pgx.Tx) error {
+		var userID, serviceName string
+		if err := tx.QueryRow(ctx, query, id).Scan(&userID, &serviceName); err != nil {
+			return err
+		}
+		if err := h.Policy.Authorize(ctx, policy.Write, userID); err != nil {
+			return err
+		}
+		return outbox.Write(ctx, tx, events.SubscriptionDeleted, map[string]string{
+			"id":           id,
+			"user_id":      userID,
+			"service_name": serviceName,
+		})
+	})
+	if errors.Is(err, policy.ErrForbidden) {
+		problem.Send(ctx, w, http.StatusForbidden, "forbidden")
+		return
+	}
+	if err != nil {
+		dbError(ctx, w, err, "Subscription not found")
 		return
 	}
 
@@ -153,15 +400,23 @@ func (h *Handler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
 
 // UpdateSubscription godoc
 // @Summary Обновить подписку
-// @Description Полностью обновляет подписку по ID
+// @Description Полностью обновляет подписку по ID. service_name и price обрабатываются так же, как при создании.
+// @Description Отменённая подписка без end_date снова становится бессрочной, отметка об отмене снимается.
+// @Description Изменение, которое перевело бы подписку в запрещённый статус (например, продление истёкшей), отклоняется с 409
 // @Tags subscriptions
 // @Accept json
 // @Produce json
 // @Param id path string true "ID подписки"
 // @Param subscription body SubscriptionUpdateRequest true "Данные подписки"
-// @Success 200 {object} Subscription
-// @Failure 400 {string} string
-// @Failure 404 {string} string
+// @Param date_format query string false "Формат дат ответа: mm-yyyy (по умолчанию), yyyy-mm, yyyy-mm-dd, rfc3339"
+// @Success 200 {object} SubscriptionResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 422 {object} BudgetViolationResponse
+// @Failure 503 {object} problem.Problem
 // @Router /subscriptions/{id} [put]
 func (h *Handler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
@@ -171,43 +426,99 @@ func (h *Handler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
 
 	var req SubscriptionUpdateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid json", http.StatusBadRequest)
+		problem.Send(ctx, w, http.StatusBadRequest, "invalid json")
 		return
 	}
 
 	s, err := req.ToModel(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Validation(ctx, w, err)
+		return
+	}
+
+	existing, err := h.loadSubscription(ctx, id)
+	if err != nil {
+		dbError(ctx, w, err, "Subscription not found")
+		return
+	}
+	if !h.authorize(ctx, w, policy.Write, existing.UserID) || !h.authorize(ctx, w, policy.Write, s.UserID) {
+		return
+	}
+	// PUT не меняет теги: для них есть /subscriptions/{id}/tags.
+	s.Tags = existing.Tags
+
+	if !h.applyCatalog(ctx, w, s, req.Price) {
+		return
+	}
+
+	violations, ok := h.enforceBudgets(ctx, w, s)
+	if !ok {
 		return
 	}
 
 	query := `
 		UPDATE subscriptions
-		SET service_name=$1, price=$2, user_id=$3, start_date=$4, end_date=$5
-		WHERE id=$6
+		SET service_id=$1, service_name=$2, price=$3, user_id=$4, start_date=$5, end_date=$6, trial_end=$7, trial_price=$8,
+		    auto_renew=$10, term_months=$11,
+		    cancel_reason = CASE WHEN $6::date IS NULL THEN NULL ELSE cancel_reason END,
+		    cancel_comment = CASE WHEN $6::date IS NULL THEN NULL ELSE cancel_comment END,
+		    cancelled_at = CASE WHEN $6::date IS NULL THEN NULL ELSE cancelled_at END
+		WHERE id=$9
 	`
-	tag, err := h.DB.Exec(ctx, query, s.ServiceName, s.Price, s.UserID, s.StartDate, s.EndDate, id)
-	if err != nil || tag.RowsAffected() == 0 {
-		slog.Error("Failed to update subscription", slog.Any("error", err))
-		http.Error(w, "Subscription not found", http.StatusNotFound)
+	err = storage.InTx(ctx, h.DB, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, query, s.ServiceID, s.ServiceName, s.Price, s.UserID, s.StartDate, s.EndDate, s.TrialEnd, s.TrialPrice, id, s.AutoRenew, s.TermMonths)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		if s.Status, err = lifecycle.Sync(ctx, tx, id); err != nil {
+			return err
+		}
+		return outbox.Write(ctx, tx, events.SubscriptionUpdated, s)
+	})
+	if isForeignKeyViolation(err) {
+		problem.Validation(ctx, w, problem.Invalid("user_id", "user does not exist"))
+		return
+	}
+	var terr *lifecycle.TransitionError
+	if errors.As(err, &terr) {
+		problem.Send(ctx, w, http.StatusConflict, terr.Error())
+		return
+	}
+	if err != nil {
+		dbError(ctx, w, err, "Subscription not found")
 		return
 	}
 
 	s.ID = id
-	json.NewEncoder(w).Encode(s)
+	json.NewEncoder(w).Encode(SubscriptionResponse{Subscription: s.In(month.FormatFrom(ctx)), BudgetViolations: violations})
 }
 
 // ListSubscriptions godoc
 // @Summary Получить подписки за период
-// @Description Фильтрация по периоду
+// @Description Фильтрация по периоду. Список ограничен ролью вызывающего: user видит свои подписки,
+// @Description manager — подписки пользователей своих команд, admin — все.
+// @Description С as_of подписки, созданные до установки миграции 018_subscriptions_history, показываются
+// @Description в состоянии на момент установки, даже если as_of раньше: их прежняя история неизвестна
 // @Tags subscriptions
 // @Accept json
 // @Produce json
-// @Param from query string false "Начало периода (MM-YYYY)"
-// @Param to query string false "Конец периода (MM-YYYY)"
+// @Param from query string false "Начало периода (MM-YYYY, YYYY-MM или YYYY-MM-DD)"
+// @Param to query string false "Конец периода (MM-YYYY, YYYY-MM или YYYY-MM-DD)"
+// @Param in_trial query bool false "Только подписки в пробном периоде (true) или вне его (false); подписки на паузе в пробный период не входят"
+// @Param status query string false "Статусы через запятую: scheduled, trial, active, paused, cancelled, expired"
+// @Param active_at query string false "Только подписки, которые действуют и не на паузе в этот день (YYYY-MM-DD или месяц — тогда его первое число)"
+// @Param tags query string false "Теги через запятую"
+// @Param tags_match query string false "any (по умолчанию) — подписки хотя бы с одним из tags, all — со всеми"
+// @Param as_of query string false "Список в состоянии на конец этого дня (YYYY-MM-DD, UTC) по истории изменений; in_trial считается на эту дату, паузы — по текущим данным"
+// @Param date_format query string false "Формат дат ответа: mm-yyyy (по умолчанию), yyyy-mm, yyyy-mm-dd, rfc3339"
 // @Success 200 {array} Subscription
-// @Failure 400 {string} string
-// @Failure 500 {string} string
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
 // @Router /subscriptions [get]
 func (h *Handler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
@@ -216,67 +527,111 @@ func (h *Handler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
 
 	var (
-		from *time.Time
-		to   *time.Time
+		from *month.Date
+		to   *month.Date
 	)
 
 	if v := q.Get("from"); v != "" {
-		t, err := parseMonth(v)
+		d, err := month.ParseStart(v)
 		if err != nil {
-			http.Error(w, "invalid from", http.StatusBadRequest)
+			problem.Validation(ctx, w, problem.Invalid("from", err.Error()))
 			return
 		}
-		from = &t
+		from = &d
 	}
 
 	if v := q.Get("to"); v != "" {
-		t, err := parseMonth(v)
+		d, err := month.ParseEnd(v)
 		if err != nil {
-			http.Error(w, "invalid to", http.StatusBadRequest)
+			problem.Validation(ctx, w, problem.Invalid("to", err.Error()))
 			return
 		}
-		to = &t
+		to = &d
 	}
 
-	query := `
-		SELECT id, service_name, price, user_id, start_date, end_date
-		FROM subscriptions
-		WHERE
-		    ($1::timestamp IS NULL OR start_date >= $1)
-		AND ($2::timestamp IS NULL OR end_date <= $2)
-		`
+	var inTrial *bool
+	if v := q.Get("in_trial"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			problem.Validation(ctx, w, problem.Invalid("in_trial", "must be true or false"))
+			return
+		}
+		inTrial = &b
+	}
 
-	rows, err := h.DB.Query(ctx, query, from, to)
-	if err != nil {
-		slog.Error("query failed", slog.Any("error", err))
-		http.Error(w, "internal error", http.StatusInternalServerError)
-		return
+	var activeAt *month.Date
+	if v := q.Get("active_at"); v != "" {
+		d, err := month.ParseStart(v)
+		if err != nil {
+			problem.Validation(ctx, w, problem.Invalid("active_at", err.Error()))
+			return
+		}
+		activeAt = &d
 	}
-	defer rows.Close()
 
-	var result []Subscription
+	var statuses []string
+	if v := q.Get("status"); v != "" {
+		for _, part := range strings.Split(v, ",") {
+			st, err := lifecycle.ParseStatus(strings.TrimSpace(part))
+			if err != nil {
+				problem.Validation(ctx, w, problem.Invalid("status", "must be a comma-separated list of scheduled, trial, active, paused, cancelled, expired"))
+				return
+			}
+			statuses = append(statuses, string(st))
+		}
+	}
 
-	for rows.Next() {
-		var s Subscription
-		err := rows.Scan(
-			&s.ID,
-			&s.ServiceName,
-			&s.Price,
-			&s.UserID,
-			&s.StartDate,
-			&s.EndDate,
-		)
-		if err != nil {
-			slog.Error("scan failed", slog.Any("error", err))
-			http.Error(w, "internal error", http.StatusInternalServerError)
+	var (
+		tags     []string
+		matchAll bool
+	)
+	if v := q.Get("tags"); v != "" {
+		var val validate.Validator
+		tags = parseTags(&val, "tags", strings.Split(v, ","))
+		switch q.Get("tags_match") {
+		case "", "any":
+		case "all":
+			matchAll = true
+		default:
+			val.Add("tags_match", "must be any or all")
+		}
+		if err := val.Err(); err != nil {
+			problem.Validation(ctx, w, err)
 			return
 		}
-		result = append(result, s)
 	}
 
-	if err := rows.Err(); err != nil {
-		slog.Error("rows error", slog.Any("error", err))
-		http.Error(w, "internal error", http.StatusInternalServerError)
+	asOf, err := parseAsOf(q.Get("as_of"))
+	if err != nil {
+		problem.Validation(ctx, w, err)
+		return
+	}
+
+	visible, ok := h.visibleUsers(ctx, w)
+	if !ok {
+		return
+	}
+
+	source := "subscriptions"
+	if asOf != nil {
+		source = historyAt("$7") + " AS subscriptions"
+	}
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM ` + source + `
+		WHERE
+		    ($1::date IS NULL OR start_date >= $1)
+		AND ($2::date IS NULL OR end_date <= $2)
+		AND ($3::uuid[] IS NULL OR user_id = ANY($3))
+		AND ($4::boolean IS NULL OR $4 = (trial_end IS NOT NULL AND start_date <= COALESCE($7::date, CURRENT_DATE) AND trial_end >= COALESCE($7::date, CURRENT_DATE) AND NOT ` + pausedOn("COALESCE($7::date, CURRENT_DATE)") + `))
+		AND ($5::date IS NULL OR (start_date <= $5 AND (end_date IS NULL OR end_date >= $5) AND NOT ` + pausedOn("$5") + `))
+		AND ($6::text[] IS NULL OR status = ANY($6))
+		AND ($8::text[] IS NULL OR ` + taggedWith("$8", "$9") + `)
+		`
+
+	result, err := h.querySubscriptions(ctx, query, from, to, visible, inTrial, activeAt, statuses, asOf, tags, matchAll)
+	if err != nil {
+		dbError(ctx, w, err, "")
 		return
 	}
 
@@ -285,57 +640,103 @@ func (h *Handler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
 }
 
 func (r SubscriptionCreateRequest) ToModel() (*Subscription, error) {
+	return buildSubscription(uuid.New().String(), r)
+}
 
-	start, err := parseMonth(r.StartDate)
-	if err != nil {
-		return nil, fmt.Errorf("invalid start_date")
+func (r SubscriptionUpdateRequest) ToModel(id string) (*Subscription, error) {
+	return buildSubscription(id, SubscriptionCreateRequest(r))
+}
+
+// buildSubscription проверяет поля запроса и возвращает все найденные ошибки разом.
+// price может отсутствовать: тогда его подставит каталог в applyCatalog.
+func buildSubscription(id string, r SubscriptionCreateRequest) (*Subscription, error) {
+	var v validate.Validator
+
+	serviceName := strings.TrimSpace(r.ServiceName)
+	if v.Required("service_name", serviceName) {
+		v.MaxLen("service_name", serviceName, maxServiceNameLen)
+	}
+
+	if v.Required("user_id", r.UserID) {
+		v.UUID("user_id", r.UserID)
+	}
+
+	if r.Price != nil {
+		v.Range("price", *r.Price, 0, maxPrice)
+	}
+
+	start, startErr := month.ParseStart(r.StartDate)
+	if v.Check(startErr == nil, "start_date", month.ErrFormat.Error()) {
+		v.Check(inDateRange(start), "start_date", monthRangeMessage)
 	}
 
-	var end *time.Time
+	var end *month.Date
 	if r.EndDate != nil {
-		parsedEnd, err := parseMonth(*r.EndDate)
-		if err != nil {
-			return nil, fmt.Errorf("invalid end_date")
+		parsedEnd, err := month.ParseEnd(*r.EndDate)
+		if v.Check(err == nil, "end_date", month.ErrFormat.Error()) {
+			v.Check(inDateRange(parsedEnd), "end_date", monthRangeMessage)
+			if startErr == nil {
+				v.Check(!parsedEnd.Before(start), "end_date", "must not be before start_date")
+			}
+			end = &parsedEnd
 		}
-		end = &parsedEnd
 	}
 
-	return &Subscription{
-		ID:          uuid.New().String(),
-		UserID:      r.UserID,
-		ServiceName: r.ServiceName,
-		Price:       r.Price,
-		StartDate:   start,
-		EndDate:     end,
-	}, nil
-}
-
-func (r SubscriptionUpdateRequest) ToModel(id string) (*Subscription, error) {
+	if r.TermMonths != nil && v.Range("term_months", *r.TermMonths, 1, maxTermMonths) && end == nil && startErr == nil {
+		// Без end_date первый срок начинается со start_date.
+		termEnd := month.DateFromTime(start.Time().AddDate(0, *r.TermMonths, -1))
+		end = &termEnd
+	}
+	if r.AutoRenew {
+		v.Check(r.TermMonths != nil, "auto_renew", "requires term_months")
+	}
 
-	start, err := parseMonth(r.StartDate)
-	if err != nil {
-		return nil, err
+	var trialEnd *month.Date
+	if r.TrialEnd != nil {
+		parsed, err := month.ParseEnd(*r.TrialEnd)
+		if v.Check(err == nil, "trial_end", month.ErrFormat.Error()) {
+			if startErr == nil {
+				v.Check(!parsed.Before(start), "trial_end", "must not be before start_date")
+			}
+			if end != nil {
+				v.Check(!parsed.After(*end), "trial_end", "must not be after end_date")
+			}
+			trialEnd = &parsed
+		}
 	}
 
-	var end *time.Time
-	if r.EndDate != nil {
-		parsedEnd, err := parseMonth(*r.EndDate)
-		if err != nil {
-			return nil, err
+	if r.TrialPrice != nil {
+		if v.Check(r.TrialEnd != nil, "trial_price", "requires trial_end") {
+			v.Range("trial_price", *r.TrialPrice, 0, maxPrice)
 		}
-		end = &parsedEnd
+	}
+
+	if err := v.Err(); err != nil {
+		return nil, err
 	}
 
 	return &Subscription{
 		ID:          id,
 		UserID:      r.UserID,
-		ServiceName: r.ServiceName,
-		Price:       r.Price,
+		ServiceName: serviceName,
+		Price:       intValue(r.Price),
 		StartDate:   start,
 		EndDate:     end,
+		TrialEnd:    trialEnd,
+		TrialPrice:  r.TrialPrice,
+		AutoRenew:   r.AutoRenew,
+		TermMonths:  r.TermMonths,
+		Tags:        []string{},
 	}, nil
 }
-func parseMonth(v string) (time.Time, error) {
 
-	return time.Parse("01-2006", v)
+func inDateRange(d month.Date) bool {
+	return !d.Before(minDate) && !d.After(maxDate)
+}
+
+func intValue(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
 }
@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"SubServices/internal/billing"
+	"SubServices/internal/events"
+	"SubServices/internal/http/problem"
+	"SubServices/internal/http/validate"
+	"SubServices/internal/lifecycle"
+	"SubServices/internal/month"
+	"SubServices/internal/outbox"
+	"SubServices/internal/policy"
+	"SubServices/internal/storage"
+)
+
+// PauseRequest приостанавливает оплату с From по Until включительно.
+// Без Until пауза длится до возобновления или до конца подписки.
+type PauseRequest struct {
+	From  string  `json:"from"`
+	Until *string `json:"until,omitempty"`
+}
+
+// ResumeRequest возобновляет оплату с дня At (по умолчанию — сегодня).
+type ResumeRequest struct {
+	At *string `json:"at,omitempty"`
+}
+
+type Pause struct {
+	ID             string      `json:"id"`
+	SubscriptionID string      `json:"subscription_id"`
+	From           month.Date  `json:"from" swaggertype:"string" example:"2025-08-01"`
+	Until          *month.Date `json:"until,omitempty" swaggertype:"string" example:"2025-09-30"`
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+var (
+	errPauseOutsidePeriod = errors.New("pause is outside the subscription period")
+	errPauseOverlaps      = errors.New("pause overlaps an existing pause")
+	errNotPaused          = errors.New("subscription is not paused")
+)
+
+// PauseSubscription godoc
+// @Summary Приостановить подписку
+// @Description Приостанавливает оплату с from по until включительно (без until — до возобновления).
+// @Description Пауза должна лежать внутри периода подписки и не пересекаться с другими паузами.
+// @Description Дни на паузе не учитываются в расчётах стоимости
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "ID подписки"
+// @Param pause body PauseRequest true "Период паузы"
+// @Success 201 {object} Pause
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /subscriptions/{id}/pause [post]
+func (h *Handler) PauseSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var req PauseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Send(ctx, w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	var v validate.Validator
+	from, fromErr := month.ParseStart(req.From)
+	v.Check(fromErr == nil, "from", month.ErrFormat.Error())
+	var until *month.Date
+	if req.Until != nil {
+		parsed, err := month.ParseEnd(*req.Until)
+		if v.Check(err == nil, "until", month.ErrFormat.Error()) {
+			if fromErr == nil {
+				v.Check(!parsed.Before(from), "until", "must not be before from")
+			}
+			until = &parsed
+		}
+	}
+	if err := v.Err(); err != nil {
+		problem.Validation(ctx, w, err)
+		return
+	}
+
+	p := Pause{ID: uuid.New().String(), SubscriptionID: chi.URLParam(r, "id"), From: from, Until: until}
+	err := storage.InTx(ctx, h.DB, func(tx pgx.Tx) error {
+		s, err := lockSubscription(ctx, tx, p.SubscriptionID)
+		if err != nil {
+			return err
+		}
+		if err := h.Policy.Authorize(ctx, policy.Write, s.UserID); err != nil {
+			return err
+		}
+		if err := insertPause(ctx, tx, s, &p); err != nil {
+			return err
+		}
+		_, err = lifecycle.Sync(ctx, tx, s.ID)
+		return err
+	})
+	var terr *lifecycle.TransitionError
+	switch {
+	case errors.Is(err, policy.ErrForbidden):
+		problem.Send(ctx, w, http.StatusForbidden, "forbidden")
+		return
+	case errors.Is(err, errPauseOutsidePeriod):
+		problem.Validation(ctx, w, problem.Invalid("from", "pause must lie within the subscription period"))
+		return
+	case errors.Is(err, errPauseOverlaps), errors.As(err, &terr):
+		problem.Send(ctx, w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		dbError(ctx, w, err, "Subscription not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(p.In(month.FormatFrom(ctx)))
+}
+
+// ResumeSubscription godoc
+// @Summary Возобновить подписку
+// @Description Завершает паузу, действующую в день at (по умолчанию — сегодня): оплата возобновляется с at.
+// @Description Пауза, которая начинается в день at, удаляется
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "ID подписки"
+// @Param resume body ResumeRequest false "Дата возобновления"
+// @Success 200 {object} Pause
+// @Success 204
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /subscriptions/{id}/resume [post]
+func (h *Handler) ResumeSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var req ResumeRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			problem.Send(ctx, w, http.StatusBadRequest, "invalid json")
+			return
+		}
+	}
+
+	at := month.DateFromTime(time.Now())
+	if req.At != nil {
+		parsed, err := month.ParseStart(*req.At)
+		if err != nil {
+			problem.Validation(ctx, w, problem.Invalid("at", month.ErrFormat.Error()))
+			return
+		}
+		at = parsed
+	}
+
+	id := chi.URLParam(r, "id")
+	var p *Pause
+	err := storage.InTx(ctx, h.DB, func(tx pgx.Tx) error {
+		s, err := lockSubscription(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := h.Policy.Authorize(ctx, policy.Write, s.UserID); err != nil {
+			return err
+		}
+		if p, err = resumeAt(ctx, tx, id, at); err != nil {
+			return err
+		}
+		_, err = lifecycle.Sync(ctx, tx, id)
+		return err
+	})
+	var terr *lifecycle.TransitionError
+	switch {
+	case errors.Is(err, policy.ErrForbidden):
+		problem.Send(ctx, w, http.StatusForbidden, "forbidden")
+		return
+	case errors.Is(err, errNotPaused), errors.As(err, &terr):
+		problem.Send(ctx, w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		dbError(ctx, w, err, "Subscription not found")
+		return
+	}
+
+	if p == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p.In(month.FormatFrom(ctx)))
+}
+
+// ListPauses godoc
+// @Summary Паузы подписки
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "ID подписки"
+// @Param date_format query string false "Формат дат ответа: mm-yyyy (по умолчанию), yyyy-mm, yyyy-mm-dd, rfc3339"
+// @Success 200 {array} Pause
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /subscriptions/{id}/pauses [get]
+func (h *Handler) ListPauses(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	s, err := h.loadSubscription(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		dbError(ctx, w, err, "Subscription not found")
+		return
+	}
+	if !h.authorize(ctx, w, policy.Read, s.UserID) {
+		return
+	}
+
+	query := `
+		SELECT id, subscription_id, pause_from, pause_until, created_at
+		FROM subscription_pauses
+		WHERE subscription_id = $1
+		ORDER BY pause_from
+	`
+	rows, err := h.DB.Query(ctx, query, s.ID)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+	result, err := pgx.CollectRows(rows, scanPause)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	format := month.FormatFrom(ctx)
+	for i := range result {
+		result[i] = result[i].In(format)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// insertPause сохраняет паузу p подписки s, заблокированной в транзакции tx.
+func insertPause(ctx context.Context, tx pgx.Tx, s Subscription, p *Pause) error {
+	if p.From.Before(s.StartDate) || (s.EndDate != nil && (p.From.After(*s.EndDate) || (p.Until != nil && p.Until.After(*s.EndDate)))) {
+		return errPauseOutsidePeriod
+	}
+
+	var overlaps bool
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM subscription_pauses
+			WHERE subscription_id = $1
+			AND pause_from <= COALESCE($3::date, 'infinity')
+			AND COALESCE(pause_until, 'infinity') >= $2
+		)
+	`
+	if err := tx.QueryRow(ctx, query, s.ID, p.From, p.Until).Scan(&overlaps); err != nil {
+		return err
+	}
+	if overlaps {
+		return errPauseOverlaps
+	}
+
+	query = `INSERT INTO subscription_pauses (id, subscription_id, pause_from, pause_until) VALUES ($1, $2, $3, $4) RETURNING created_at`
+	if err := tx.QueryRow(ctx, query, p.ID, s.ID, p.From, p.Until).Scan(&p.CreatedAt); err != nil {
+		return err
+	}
+	return outbox.Write(ctx, tx, events.SubscriptionPaused, p)
+}
+
+// resumeAt завершает паузу подписки id, действующую в день at. Пауза, которая начинается
+// в этот день, удаляется, и тогда возвращается nil.
+func resumeAt(ctx context.Context, tx pgx.Tx, id string, at month.Date) (*Pause, error) {
+	query := `
+		SELECT id, subscription_id, pause_from, pause_until, created_at
+		FROM subscription_pauses
+		WHERE subscription_id = $1
+		AND pause_from <= $2
+		AND (pause_until IS NULL OR pause_until >= $2)
+	`
+	rows, _ := tx.Query(ctx, query, id, at)
+	found, err := pgx.CollectExactlyOneRow(rows, scanPause)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errNotPaused
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var p *Pause
+	if !found.From.Before(at) {
+		if _, err := tx.Exec(ctx, `DELETE FROM subscription_pauses WHERE id = $1`, found.ID); err != nil {
+			return nil, err
+		}
+	} else {
+		until := month.DateFromTime(at.Time().AddDate(0, 0, -1))
+		if _, err := tx.Exec(ctx, `UPDATE subscription_pauses SET pause_until = $1 WHERE id = $2`, until, found.ID); err != nil {
+			return nil, err
+		}
+		found.Until = &until
+		p = &found
+	}
+	return p, outbox.Write(ctx, tx, events.SubscriptionResumed, map[string]any{
+		"subscription_id": id,
+		"pause_id":        found.ID,
+		"resumed_at":      at,
+	})
+}
+
+// In возвращает копию паузы, даты которой выводятся в формате f.
+func (p Pause) In(f month.Format) Pause {
+	p.From = p.From.In(f)
+	if p.Until != nil {
+		until := p.Until.AsEnd().In(f)
+		p.Until = &until
+	}
+	return p
+}
+
+func scanPause(row pgx.CollectableRow) (Pause, error) {
+	var p Pause
+	err := row.Scan(&p.ID, &p.SubscriptionID, &p.From, &p.Until, &p.CreatedAt)
+	return p, err
+}
+
+// lockSubscription загружает подписку и блокирует её строку до конца транзакции,
+// чтобы параллельные изменения пауз не пересеклись.
+func lockSubscription(ctx context.Context, tx pgx.Tx, id string) (Subscription, error) {
+	rows, _ := tx.Query(ctx, `SELECT `+subscriptionColumns+` FROM subscriptions WHERE id = $1 FOR UPDATE`, id)
+	return pgx.CollectExactlyOneRow(rows, scanSubscription)
+}
+
+// pausedOn возвращает SQL-условие «подписка на паузе в день date» для запросов к subscriptions.
+func pausedOn(date string) string {
+	return `EXISTS (
+			SELECT 1 FROM subscription_pauses p
+			WHERE p.subscription_id = subscriptions.id
+			AND p.pause_from <= ` + date + `
+			AND (p.pause_until IS NULL OR p.pause_until >= ` + date + `)
+		)`
+}
+
+// loadPauses дополняет подписки из items их паузами.
+func (h *Handler) loadPauses(ctx context.Context, items []billing.Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+	index, ids := indexItems(items)
+
+	query := `SELECT subscription_id, pause_from, pause_until FROM subscription_pauses WHERE subscription_id = ANY($1)`
+	rows, err := h.DB.Query(ctx, query, ids)
+	if err != nil {
+		return err
+	}
+	var subscriptionID string
+	var p billing.Pause
+	_, err = pgx.ForEachRow(rows, []any{&subscriptionID, &p.From, &p.Until}, func() error {
+		for _, i := range index[subscriptionID] {
+			items[i].Pauses = append(items[i].Pauses, p)
+		}
+		return nil
+	})
+	return err
+}
+
+// indexItems возвращает позиции подписок в items по ID и список различных ID.
+func indexItems(items []billing.Item) (map[string][]int, []string) {
+	index := make(map[string][]int, len(items))
+	ids := make([]string, 0, len(items))
+	for i, it := range items {
+		if it.ID == "" {
+			continue
+		}
+		if _, ok := index[it.ID]; !ok {
+			ids = append(ids, it.ID)
+		}
+		index[it.ID] = append(index[it.ID], i)
+	}
+	return index, ids
+}
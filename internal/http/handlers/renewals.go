@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"SubServices/internal/month"
+	"SubServices/internal/policy"
+)
+
+type Renewal struct {
+	ID             string `json:"id"`
+	SubscriptionID string `json:"subscription_id"`
+	// SuccessorID — подписка, созданная продлением в режиме successor.
+	SuccessorID *string    `json:"successor_id,omitempty"`
+	Mode        string     `json:"mode" example:"extend"`
+	PreviousEnd month.Date `json:"previous_end" swaggertype:"string" example:"2025-06-30"`
+	NewEnd      month.Date `json:"new_end" swaggertype:"string" example:"2025-07-31"`
+	RenewedAt   time.Time  `json:"renewed_at"`
+}
+
+// ListRenewals godoc
+// @Summary История продлений подписки
+// @Description Продления подписки и продление, которым она создана (в режиме successor)
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "ID подписки"
+// @Param date_format query string false "Формат дат ответа: mm-yyyy (по умолчанию), yyyy-mm, yyyy-mm-dd, rfc3339"
+// @Success 200 {array} Renewal
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /subscriptions/{id}/renewals [get]
+func (h *Handler) ListRenewals(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	s, err := h.loadSubscription(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		dbError(ctx, w, err, "Subscription not found")
+		return
+	}
+	if !h.authorize(ctx, w, policy.Read, s.UserID) {
+		return
+	}
+
+	query := `
+		SELECT id, subscription_id, successor_id, mode, previous_end, new_end, renewed_at
+		FROM subscription_renewals
+		WHERE subscription_id = $1 OR successor_id = $1
+		ORDER BY renewed_at, previous_end
+	`
+	rows, err := h.DB.Query(ctx, query, s.ID)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+	format := month.FormatFrom(ctx)
+	result, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Renewal, error) {
+		var rn Renewal
+		err := row.Scan(&rn.ID, &rn.SubscriptionID, &rn.SuccessorID, &rn.Mode, &rn.PreviousEnd, &rn.NewEnd, &rn.RenewedAt)
+		rn.PreviousEnd = rn.PreviousEnd.AsEnd().In(format)
+		rn.NewEnd = rn.NewEnd.AsEnd().In(format)
+		return rn, err
+	})
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
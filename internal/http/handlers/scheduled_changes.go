@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"SubServices/internal/billing"
+	"SubServices/internal/changes"
+	"SubServices/internal/http/problem"
+	"SubServices/internal/http/validate"
+	"SubServices/internal/lifecycle"
+	"SubServices/internal/month"
+	"SubServices/internal/policy"
+	"SubServices/internal/storage"
+)
+
+// ScheduledChangeRequest задаёт изменения подписки, которые вступят в силу с первого
+// дня EffectiveMonth. end_date принимается в тех же форматах, что и при создании подписки.
+type ScheduledChangeRequest struct {
+	EffectiveMonth string         `json:"effective_month" example:"03-2026"`
+	Changes        changes.Fields `json:"changes"`
+}
+
+type ScheduledChange struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	EffectiveMonth month.Month     `json:"effective_month" swaggertype:"string" example:"03-2026"`
+	Changes        changes.Fields  `json:"changes"`
+	Status         string          `json:"status" example:"pending"`
+	Previous       *changes.Fields `json:"previous,omitempty"`
+	Error          *string         `json:"error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	AppliedAt      *time.Time      `json:"applied_at,omitempty"`
+}
+
+var scheduledChangeStatuses = []string{changes.StatusPending, changes.StatusApplied, changes.StatusFailed, changes.StatusCancelled}
+
+// CreateScheduledChange godoc
+// @Summary Запланировать изменение подписки
+// @Description Изменения price, end_date, auto_renew, term_months или отмена (cancel) применяются фоновой задачей
+// @Description в первый день effective_month одной транзакцией. Отмена делает последним оплачиваемым днём
+// @Description день перед effective_month. Новая цена учитывается в стоимости только с effective_month.
+// @Description effective_month — будущий месяц внутри периода подписки
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "ID подписки"
+// @Param change body ScheduledChangeRequest true "Изменение"
+// @Param date_format query string false "Формат дат ответа: mm-yyyy (по умолчанию), yyyy-mm, yyyy-mm-dd, rfc3339"
+// @Success 201 {object} ScheduledChange
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /subscriptions/{id}/scheduled-changes [post]
+func (h *Handler) CreateScheduledChange(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var req ScheduledChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Send(ctx, w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	c, err := req.ToModel()
+	if err != nil {
+		problem.Validation(ctx, w, err)
+		return
+	}
+
+	s, err := h.loadSubscription(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		dbError(ctx, w, err, "Subscription not found")
+		return
+	}
+	if !h.authorize(ctx, w, policy.Write, s.UserID) {
+		return
+	}
+	if s.Cancellation != nil {
+		problem.Send(ctx, w, http.StatusConflict, lifecycle.ErrAlreadyCancelled.Error())
+		return
+	}
+
+	var v validate.Validator
+	if s.EndDate != nil {
+		v.Check(!c.EffectiveMonth.After(s.EndDate.Month()), "effective_month", "must not be after end_date")
+	}
+	if c.Changes.AutoRenew != nil && *c.Changes.AutoRenew {
+		v.Check(c.Changes.TermMonths != nil || s.TermMonths != nil, "changes.auto_renew", "requires term_months")
+	}
+	if err := v.Err(); err != nil {
+		problem.Validation(ctx, w, err)
+		return
+	}
+
+	c.SubscriptionID = s.ID
+	query := `
+		INSERT INTO scheduled_changes (id, subscription_id, effective_month, changes)
+		VALUES ($1, $2, $3, $4)
+		RETURNING status, created_at
+	`
+	if err := h.DB.QueryRow(ctx, query, c.ID, c.SubscriptionID, c.EffectiveMonth, c.Changes).Scan(&c.Status, &c.CreatedAt); err != nil {
+		dbError(ctx, w, err, "Subscription not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c.In(month.FormatFrom(ctx)))
+}
+
+// ToModel проверяет запрос и приводит end_date к changes.DateLayout.
+func (r ScheduledChangeRequest) ToModel() (*ScheduledChange, error) {
+	var v validate.Validator
+
+	m, err := month.Parse(r.EffectiveMonth)
+	if v.Check(err == nil, "effective_month", month.ErrFormat.Error()) {
+		v.Check(m.After(month.FromTime(time.Now())), "effective_month", "must be a future month")
+	}
+
+	f := r.Changes
+	v.Check(f.Price != nil || f.EndDate != nil || f.AutoRenew != nil || f.TermMonths != nil || f.Cancel != nil,
+		"changes", "must change at least one field")
+	if f.Price != nil {
+		v.Range("changes.price", *f.Price, 0, maxPrice)
+	}
+	if f.TermMonths != nil {
+		v.Range("changes.term_months", *f.TermMonths, 1, maxTermMonths)
+	}
+	if f.EndDate != nil {
+		end, err := month.ParseEnd(*f.EndDate)
+		if v.Check(err == nil, "changes.end_date", month.ErrFormat.Error()) && v.Check(inDateRange(end), "changes.end_date", monthRangeMessage) {
+			normalized := end.Time().Format(changes.DateLayout)
+			f.EndDate = &normalized
+		}
+		v.Check(f.Cancel == nil, "changes.end_date", "cannot be combined with cancel")
+	}
+	if f.Cancel != nil {
+		if v.Required("changes.cancel.reason", f.Cancel.Reason) {
+			v.Check(slices.Contains(cancelReasons, f.Cancel.Reason), "changes.cancel.reason", "must be one of "+strings.Join(cancelReasons, ", "))
+		}
+		if f.Cancel.Comment != nil {
+			v.MaxLen("changes.cancel.comment", *f.Cancel.Comment, maxCancelCommentLen)
+		}
+	}
+
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+	return &ScheduledChange{ID: uuid.New().String(), EffectiveMonth: m, Changes: f}, nil
+}
+
+// ListScheduledChanges godoc
+// @Summary Запланированные изменения подписки
+// @Description Все изменения подписки с результатом применения; status фильтрует по состоянию
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "ID подписки"
+// @Param status query string false "pending, applied, failed или cancelled"
+// @Param date_format query string false "Формат дат ответа: mm-yyyy (по умолчанию), yyyy-mm, yyyy-mm-dd, rfc3339"
+// @Success 200 {array} ScheduledChange
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /subscriptions/{id}/scheduled-changes [get]
+func (h *Handler) ListScheduledChanges(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	status := r.URL.Query().Get("status")
+	if status != "" && !slices.Contains(scheduledChangeStatuses, status) {
+		problem.Validation(ctx, w, problem.Invalid("status", "must be one of "+strings.Join(scheduledChangeStatuses, ", ")))
+		return
+	}
+
+	s, err := h.loadSubscription(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		dbError(ctx, w, err, "Subscription not found")
+		return
+	}
+	if !h.authorize(ctx, w, policy.Read, s.UserID) {
+		return
+	}
+
+	query := `
+		SELECT id, subscription_id, effective_month, changes, status, previous, error, created_at, applied_at
+		FROM scheduled_changes
+		WHERE subscription_id = $1
+		AND ($2 = '' OR status = $2)
+		ORDER BY effective_month, created_at
+	`
+	rows, err := h.DB.Query(ctx, query, s.ID, status)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+	format := month.FormatFrom(ctx)
+	result, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (ScheduledChange, error) {
+		var c ScheduledChange
+		err := row.Scan(&c.ID, &c.SubscriptionID, &c.EffectiveMonth, &c.Changes, &c.Status, &c.Previous, &c.Error, &c.CreatedAt, &c.AppliedAt)
+		return c.In(format), err
+	})
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// CancelScheduledChange godoc
+// @Summary Отменить запланированное изменение
+// @Description Отменить можно только изменение в статусе pending; оно остаётся в списке со статусом cancelled
+// @Tags subscriptions
+// @Param id path string true "ID подписки"
+// @Param change_id path string true "ID изменения"
+// @Success 204
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /subscriptions/{id}/scheduled-changes/{change_id} [delete]
+func (h *Handler) CancelScheduledChange(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	s, err := h.loadSubscription(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		dbError(ctx, w, err, "Subscription not found")
+		return
+	}
+	if !h.authorize(ctx, w, policy.Write, s.UserID) {
+		return
+	}
+
+	var status string
+	err = storage.InTx(ctx, h.DB, func(tx pgx.Tx) error {
+		query := `SELECT status FROM scheduled_changes WHERE id = $1 AND subscription_id = $2 FOR UPDATE`
+		if err := tx.QueryRow(ctx, query, chi.URLParam(r, "change_id"), s.ID).Scan(&status); err != nil {
+			return err
+		}
+		if status != changes.StatusPending {
+			return nil
+		}
+		_, err := tx.Exec(ctx, `UPDATE scheduled_changes SET status = 'cancelled' WHERE id = $1`, chi.URLParam(r, "change_id"))
+		return err
+	})
+	if err != nil {
+		dbError(ctx, w, err, "Scheduled change not found")
+		return
+	}
+	if status != changes.StatusPending {
+		problem.Send(ctx, w, http.StatusConflict, "scheduled change is already "+status)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// In возвращает копию изменения, месяц которого выводится в формате f.
+func (c ScheduledChange) In(f month.Format) ScheduledChange {
+	c.EffectiveMonth = c.EffectiveMonth.In(f)
+	return c
+}
+
+// loadPriceChanges дополняет подписки из items сменами цены в порядке месяцев.
+func (h *Handler) loadPriceChanges(ctx context.Context, items []billing.Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+	index, ids := indexItems(items)
+
+	query := `
+		SELECT subscription_id, effective_month, previous_price
+		FROM subscription_price_changes
+		WHERE subscription_id = ANY($1)
+		ORDER BY effective_month
+	`
+	rows, err := h.DB.Query(ctx, query, ids)
+	if err != nil {
+		return err
+	}
+	var subscriptionID string
+	var c billing.PriceChange
+	_, err = pgx.ForEachRow(rows, []any{&subscriptionID, &c.From, &c.Previous}, func() error {
+		for _, i := range index[subscriptionID] {
+			items[i].PriceChanges = append(items[i].PriceChanges, c)
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,453 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"SubServices/internal/catalog"
+	"SubServices/internal/http/problem"
+	"SubServices/internal/policy"
+	"SubServices/internal/storage"
+)
+
+type ServiceRequest struct {
+	Name         string   `json:"name"`
+	Aliases      []string `json:"aliases,omitempty"`
+	Category     *string  `json:"category,omitempty"`
+	DefaultPrice *int     `json:"default_price,omitempty"`
+}
+
+type Service struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Aliases      []string  `json:"aliases"`
+	Category     *string   `json:"category,omitempty"`
+	DefaultPrice *int      `json:"default_price,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// CreateService godoc
+// @Summary Добавить сервис в каталог
+// @Description Создаёт сервис с каноническим названием и алиасами. Сравнение алиасов не зависит от регистра,
+// @Description пробелов и алфавита (кириллица транслитерируется). Существующие подписки с совпадающим
+// @Description service_name привязываются к сервису и получают каноническое название, бюджеты — тоже
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param service body ServiceRequest true "Данные сервиса"
+// @Success 201 {object} map[string]string
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /services [post]
+func (h *Handler) CreateService(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !h.authorize(ctx, w, policy.Manage, "") {
+		return
+	}
+
+	var req ServiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Send(ctx, w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if err := req.validate(); err != nil {
+		problem.Validation(ctx, w, err)
+		return
+	}
+
+	id := uuid.New().String()
+	err := storage.InTx(ctx, h.DB, func(tx pgx.Tx) error {
+		query := `INSERT INTO services (id, name, category, default_price) VALUES ($1, $2, $3, $4)`
+		if _, err := tx.Exec(ctx, query, id, req.Name, req.Category, req.DefaultPrice); err != nil {
+			return err
+		}
+		return saveAliases(ctx, tx, id, req)
+	})
+	if isUniqueViolation(err) {
+		problem.Send(ctx, w, http.StatusConflict, "Service name or alias already exists")
+		return
+	}
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"id": id})
+}
+
+// ListServices godoc
+// @Summary Получить каталог сервисов
+// @Tags services
+// @Produce json
+// @Param category query string false "Категория"
+// @Success 200 {array} Service
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /services [get]
+func (h *Handler) ListServices(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT s.id, s.name, s.category, s.default_price, s.created_at,
+		       COALESCE(array_agg(a.alias ORDER BY a.alias) FILTER (WHERE a.alias IS NOT NULL), '{}')
+		FROM services s
+		LEFT JOIN service_aliases a ON a.service_id = s.id
+		WHERE ($1 = '' OR s.category = $1)
+		GROUP BY s.id
+		ORDER BY s.name
+	`
+	rows, err := h.DB.Query(ctx, query, r.URL.Query().Get("category"))
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	result, err := pgx.CollectRows(rows, scanService)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetService godoc
+// @Summary Получить сервис
+// @Tags services
+// @Produce json
+// @Param id path string true "ID сервиса"
+// @Success 200 {object} Service
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /services/{id} [get]
+func (h *Handler) GetService(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	svc, err := h.loadService(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		dbError(ctx, w, err, "Service not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(svc)
+}
+
+// UpdateService godoc
+// @Summary Обновить сервис
+// @Description Полностью обновляет сервис, включая список алиасов. Привязанные подписки и бюджеты сервиса получают новое каноническое название
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param id path string true "ID сервиса"
+// @Param service body ServiceRequest true "Данные сервиса"
+// @Success 200 {object} Service
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /services/{id} [put]
+func (h *Handler) UpdateService(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !h.authorize(ctx, w, policy.Manage, "") {
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	var req ServiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Send(ctx, w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if err := req.validate(); err != nil {
+		problem.Validation(ctx, w, err)
+		return
+	}
+
+	err := storage.InTx(ctx, h.DB, func(tx pgx.Tx) error {
+		// Бюджеты не ссылаются на сервис по id, поэтому переименовываются по прежнему
+		// каноническому названию, пока оно ещё есть в services.
+		query := `UPDATE budgets SET service_name = $1 WHERE service_name = (SELECT name FROM services WHERE id = $2)`
+		if _, err := tx.Exec(ctx, query, req.Name, id); err != nil {
+			return err
+		}
+
+		query = `UPDATE services SET name=$1, category=$2, default_price=$3 WHERE id=$4`
+		tag, err := tx.Exec(ctx, query, req.Name, req.Category, req.DefaultPrice, id)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+
+		if _, err := tx.Exec(ctx, `DELETE FROM service_aliases WHERE service_id = $1`, id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE subscriptions SET service_name = $1 WHERE service_id = $2`, req.Name, id); err != nil {
+			return err
+		}
+		return saveAliases(ctx, tx, id, req)
+	})
+	if isUniqueViolation(err) {
+		problem.Send(ctx, w, http.StatusConflict, "Service name or alias already exists")
+		return
+	}
+	if err != nil {
+		dbError(ctx, w, err, "Service not found")
+		return
+	}
+
+	svc, err := h.loadService(ctx, id)
+	if err != nil {
+		dbError(ctx, w, err, "Service not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(svc)
+}
+
+// DeleteService godoc
+// @Summary Удалить сервис
+// @Description Удаляет сервис из каталога. Подписки сохраняют service_name, но теряют привязку service_id
+// @Tags services
+// @Param id path string true "ID сервиса"
+// @Success 204
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /services/{id} [delete]
+func (h *Handler) DeleteService(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !h.authorize(ctx, w, policy.Manage, "") {
+		return
+	}
+
+	tag, err := h.DB.Exec(ctx, `DELETE FROM services WHERE id = $1`, chi.URLParam(r, "id"))
+	if err != nil {
+		dbError(ctx, w, err, "Service not found")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		problem.Send(ctx, w, http.StatusNotFound, "Service not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyCatalog сопоставляет service_name подписки с каталогом: при совпадении
+// подставляет service_id и каноническое название, а при отсутствии price — цену
+// сервиса по умолчанию. Возвращает false, если ответ уже записан.
+func (h *Handler) applyCatalog(ctx context.Context, w http.ResponseWriter, s *Subscription, price *int) bool {
+	id, name, defaultPrice, err := h.findService(ctx, s.ServiceName)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		s.ServiceID = nil
+	case err != nil:
+		dbError(ctx, w, err, "")
+		return false
+	default:
+		s.ServiceID = &id
+		s.ServiceName = name
+	}
+
+	if price == nil {
+		if defaultPrice == nil {
+			problem.Validation(ctx, w, problem.Invalid("price", "is required when the service has no default price"))
+			return false
+		}
+		s.Price = *defaultPrice
+	}
+
+	return true
+}
+
+// applyBudgetCatalog заменяет service_name бюджета каноническим названием из каталога,
+// чтобы бюджет совпадал с подписками на сервис. Возвращает false, если ответ уже записан.
+func (h *Handler) applyBudgetCatalog(ctx context.Context, w http.ResponseWriter, req *BudgetRequest) bool {
+	if req.ServiceName == nil {
+		return true
+	}
+	name := strings.TrimSpace(*req.ServiceName)
+	_, canonical, _, err := h.findService(ctx, name)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		dbError(ctx, w, err, "")
+		return false
+	default:
+		name = canonical
+	}
+	req.ServiceName = &name
+	return true
+}
+
+// findService ищет в каталоге сервис по названию или алиасу. Возвращает
+// pgx.ErrNoRows, если такого сервиса нет.
+func (h *Handler) findService(ctx context.Context, name string) (id, canonical string, defaultPrice *int, err error) {
+	query := `
+		SELECT s.id, s.name, s.default_price
+		FROM service_aliases a
+		JOIN services s ON s.id = a.service_id
+		WHERE a.alias_key = $1
+	`
+	err = h.DB.QueryRow(ctx, query, catalog.Key(name)).Scan(&id, &canonical, &defaultPrice)
+	return id, canonical, defaultPrice, err
+}
+
+// serviceNames возвращает названия подписок, подходящие под фильтр service_name:
+// сам фильтр, каноническое название сервиса, найденного по нему в каталоге, и названия
+// не привязанных к каталогу подписок с тем же catalog.Key. Пустой фильтр даёт nil.
+func (h *Handler) serviceNames(ctx context.Context, filter string) ([]string, error) {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return nil, nil
+	}
+
+	names := []string{filter}
+	_, canonical, _, err := h.findService(ctx, filter)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		return nil, err
+	default:
+		names = append(names, canonical)
+	}
+
+	key := catalog.Key(filter)
+	if key == "" {
+		return names, nil
+	}
+	rows, err := h.DB.Query(ctx, `SELECT DISTINCT service_name FROM subscriptions WHERE service_id IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	unlinked, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range unlinked {
+		if catalog.Key(name) == key && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (h *Handler) loadService(ctx context.Context, id string) (Service, error) {
+	query := `
+		SELECT s.id, s.name, s.category, s.default_price, s.created_at,
+		       COALESCE(array_agg(a.alias ORDER BY a.alias) FILTER (WHERE a.alias IS NOT NULL), '{}')
+		FROM services s
+		LEFT JOIN service_aliases a ON a.service_id = s.id
+		WHERE s.id = $1
+		GROUP BY s.id
+	`
+	rows, _ := h.DB.Query(ctx, query, id)
+	return pgx.CollectExactlyOneRow(rows, scanService)
+}
+
+// saveAliases сохраняет каноническое название и алиасы сервиса, привязывает к нему
+// ещё не привязанные подписки, чьё название совпадает с одним из алиасов, и даёт
+// таким бюджетам каноническое название.
+func saveAliases(ctx context.Context, tx pgx.Tx, serviceID string, req ServiceRequest) error {
+	keys := make(map[string]string)
+	for _, alias := range append([]string{req.Name}, req.Aliases...) {
+		alias = strings.TrimSpace(alias)
+		if key := catalog.Key(alias); key != "" {
+			if _, ok := keys[key]; !ok {
+				keys[key] = alias
+			}
+		}
+	}
+
+	for key, alias := range keys {
+		query := `INSERT INTO service_aliases (service_id, alias, alias_key) VALUES ($1, $2, $3)`
+		if _, err := tx.Exec(ctx, query, serviceID, alias, key); err != nil {
+			return err
+		}
+	}
+
+	matched, err := matchAliases(ctx, tx, `SELECT DISTINCT service_name FROM subscriptions WHERE service_id IS NULL`, keys)
+	if err != nil {
+		return err
+	}
+	if len(matched) > 0 {
+		query := `
+			UPDATE subscriptions
+			SET service_id = $1, service_name = $2
+			WHERE service_id IS NULL AND service_name = ANY($3)
+		`
+		if _, err := tx.Exec(ctx, query, serviceID, req.Name, matched); err != nil {
+			return err
+		}
+	}
+
+	matched, err = matchAliases(ctx, tx, `SELECT DISTINCT service_name FROM budgets WHERE service_name IS NOT NULL`, keys)
+	if err != nil || len(matched) == 0 {
+		return err
+	}
+	_, err = tx.Exec(ctx, `UPDATE budgets SET service_name = $1 WHERE service_name = ANY($2)`, req.Name, matched)
+	return err
+}
+
+// matchAliases возвращает названия из запроса query, чей catalog.Key входит в keys.
+func matchAliases(ctx context.Context, tx pgx.Tx, query string, keys map[string]string) ([]string, error) {
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []string
+	for _, name := range names {
+		if _, ok := keys[catalog.Key(name)]; ok {
+			matched = append(matched, name)
+		}
+	}
+	return matched, nil
+}
+
+func scanService(row pgx.CollectableRow) (Service, error) {
+	var svc Service
+	err := row.Scan(&svc.ID, &svc.Name, &svc.Category, &svc.DefaultPrice, &svc.CreatedAt, &svc.Aliases)
+	return svc, err
+}
+
+func (r *ServiceRequest) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if catalog.Key(r.Name) == "" {
+		return problem.Invalid("name", "must contain letters or digits")
+	}
+	if r.DefaultPrice != nil && *r.DefaultPrice < 0 {
+		return problem.Invalid("default_price", "must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+
+	"SubServices/internal/events"
+	"SubServices/internal/http/problem"
+	"SubServices/internal/storage"
+)
+
+// streamFilter — поля данных события, по которым можно фильтровать поток.
+type streamFilter struct {
+	UserID      string `json:"user_id"`
+	ServiceName string `json:"service_name"`
+
+	// visible — пользователи, чьи события доступны вызывающему; nil — все.
+	visible map[string]bool
+}
+
+// StreamSubscriptions godoc
+// @Summary Поток изменений подписок (SSE)
+// @Description Server-Sent Events с событиями subscription.created|updated|deleted. Поле id события можно
+// @Description передать в заголовке Last-Event-ID, чтобы получить пропущенные события из журнала. Если они
+// @Description уже вытеснены или id выдан другим экземпляром сервиса (либо до его перезапуска), первым приходит
+// @Description событие reset — клиенту нужно перечитать список подписок.
+// @Description Каждые stream.heartbeat отправляется комментарий, чтобы прокси не закрывали соединение
+// @Tags subscriptions
+// @Produce text/event-stream
+// @Param user_id query string false "ID пользователя"
+// @Param service_name query string false "Название сервиса"
+// @Param Last-Event-ID header string false "ID последнего полученного события"
+// @Success 200 {string} string
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Router /subscriptions/stream [get]
+func (h *Handler) StreamSubscriptions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := streamFilter{UserID: q.Get("user_id"), ServiceName: q.Get("service_name")}
+
+	// Поток ограничен теми же пользователями, что и список подписок.
+	visible, ok := h.visibleUsers(r.Context(), w)
+	if !ok {
+		return
+	}
+	if visible != nil {
+		filter.visible = make(map[string]bool, len(visible))
+		for _, id := range visible {
+			filter.visible[id] = true
+		}
+		if filter.UserID != "" && !filter.visible[filter.UserID] {
+			problem.Send(r.Context(), w, http.StatusForbidden, "forbidden")
+			return
+		}
+	}
+
+	// Соединение живёт дольше http_server.timeout, поэтому дедлайн записи снимается.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		problem.Send(r.Context(), w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	backlog, ch, complete, cancel := h.Broker.Subscribe(r.Header.Get("Last-Event-ID"))
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, entry := range backlog {
+		writeStreamEntry(w, entry, filter)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.Cfg.Stream.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case entry, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeStreamEntry(w, entry, filter)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// PublishChange переводит уведомление об изменении подписки, пришедшее от любого
+// экземпляра сервиса, в событие SSE-потока этого экземпляра.
+func (h *Handler) PublishChange(ctx context.Context, n storage.Notification) {
+	var (
+		typ  string
+		data any
+	)
+	switch n.Op {
+	case storage.OpInsert:
+		typ = events.SubscriptionCreated
+	case storage.OpUpdate:
+		typ = events.SubscriptionUpdated
+	case storage.OpDelete:
+		typ = events.SubscriptionDeleted
+		data = map[string]string{"id": n.ID, "user_id": n.UserID, "service_name": n.ServiceName}
+	default:
+		return
+	}
+
+	if data == nil {
+		s, err := h.loadSubscription(ctx, n.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Подписка уже удалена, событие удаления придёт отдельным уведомлением.
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to load changed subscription", slog.String("id", n.ID), slog.Any("error", err))
+			return
+		}
+		data = s
+	}
+
+	e, err := events.New(typ, data)
+	if err != nil {
+		slog.Error("Failed to build stream event", slog.String("id", n.ID), slog.Any("error", err))
+		return
+	}
+	h.Broker.Publish(ctx, e)
+}
+
+func writeStreamEntry(w http.ResponseWriter, entry events.Entry, filter streamFilter) {
+	switch entry.Event.Type {
+	case events.SubscriptionCreated, events.SubscriptionUpdated, events.SubscriptionDeleted:
+	default:
+		return
+	}
+
+	if filter.UserID != "" || filter.ServiceName != "" || filter.visible != nil {
+		var data streamFilter
+		if err := json.Unmarshal(entry.Event.Data, &data); err != nil {
+			return
+		}
+		if filter.visible != nil && !filter.visible[data.UserID] {
+			return
+		}
+		if filter.UserID != "" && data.UserID != filter.UserID {
+			return
+		}
+		if filter.ServiceName != "" && data.ServiceName != filter.ServiceName {
+			return
+		}
+	}
+
+	payload, err := json.Marshal(entry.Event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", entry.ID, entry.Event.Type, payload)
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"SubServices/internal/billing"
+	"SubServices/internal/http/problem"
+	"SubServices/internal/http/validate"
+	"SubServices/internal/month"
+	"SubServices/internal/policy"
+)
+
+type SummaryMonth struct {
+	Month month.Month `json:"month" swaggertype:"string" example:"07-2025"`
+	Total int         `json:"total"`
+}
+
+// SummaryTag — стоимость подписок с тегом Tag; Tag равен null для подписок без тегов.
+type SummaryTag struct {
+	Tag    *string        `json:"tag" example:"entertainment"`
+	Total  int            `json:"total"`
+	Months []SummaryMonth `json:"months"`
+}
+
+type SubscriptionSummary struct {
+	From      month.Month       `json:"from" swaggertype:"string" example:"01-2025"`
+	To        month.Month       `json:"to" swaggertype:"string" example:"12-2025"`
+	Proration billing.Proration `json:"proration" swaggertype:"string" example:"daily"`
+	Total     int               `json:"total"`
+	Months    []SummaryMonth    `json:"months"`
+	// Tags заполняется при group_by=tag.
+	Tags []SummaryTag `json:"tags,omitempty"`
+}
+
+// SubscriptionSummary godoc
+// @Summary Суммарная стоимость подписок
+// @Description Стоимость подписок за период по месяцам. По умолчанию — текущий месяц.
+// @Description proration задаёт расчёт неполных месяцев: none — месяц оплачивается целиком,
+// @Description daily — price × дни подписки / дни месяца с округлением половины вверх,
+// @Description half_month — каждая половина месяца (1–15, 16–конец) с хотя бы одним днём подписки
+// @Description стоит половину цены. Дни на паузе не оплачиваются. Округляется стоимость каждой подписки за месяц, итоги — суммы округлённых значений.
+// @Description group_by=tag добавляет итоги по тегам: подписка с несколькими тегами входит в итог каждого из них,
+// @Description подписки без тегов собираются в группу с tag = null
+// @Tags subscriptions
+// @Produce json
+// @Param from query string false "Начало периода (MM-YYYY, YYYY-MM или YYYY-MM-DD)"
+// @Param to query string false "Конец периода (MM-YYYY, YYYY-MM или YYYY-MM-DD)"
+// @Param user_id query string false "ID пользователя"
+// @Param service_name query string false "Название сервиса или его алиас; сравнивается без учёта регистра, пробелов и алфавита"
+// @Param proration query string false "none, daily или half_month; по умолчанию billing.proration"
+// @Param group_by query string false "tag — итоги по тегам"
+// @Param date_format query string false "Формат дат ответа: mm-yyyy (по умолчанию), yyyy-mm, yyyy-mm-dd, rfc3339"
+// @Success 200 {object} SubscriptionSummary
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /subscriptions/summary [get]
+func (h *Handler) SubscriptionSummary(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	q := r.URL.Query()
+
+	var v validate.Validator
+	from, to := reportPeriod(&v, q)
+	mode := h.proration(&v, q)
+	var userID *string
+	if id := q.Get("user_id"); id != "" && v.UUID("user_id", id) {
+		userID = &id
+	}
+	groupBy := q.Get("group_by")
+	v.Check(groupBy == "" || groupBy == "tag", "group_by", "must be tag")
+	if err := v.Err(); err != nil {
+		problem.Validation(ctx, w, err)
+		return
+	}
+
+	var visible []string
+	if userID != nil {
+		if !h.authorize(ctx, w, policy.Read, *userID) {
+			return
+		}
+	} else {
+		var ok bool
+		if visible, ok = h.visibleUsers(ctx, w); !ok {
+			return
+		}
+	}
+
+	services, err := h.serviceNames(ctx, q.Get("service_name"))
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	query := `
+		SELECT id, service_name, price, start_date, end_date, trial_end, trial_price
+		FROM subscriptions
+		WHERE ($1::uuid IS NULL OR user_id = $1)
+		AND ($2::text[] IS NULL OR service_name = ANY($2))
+		AND ($3::uuid[] IS NULL OR user_id = ANY($3))
+		AND (end_date IS NULL OR end_date >= $4)
+		AND start_date <= $5
+	`
+	rows, err := h.DB.Query(ctx, query, userID, services, visible, from, billing.MonthEnd(to))
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+	items, err := pgx.CollectRows(rows, scanBillingItem)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+	if err := h.loadPauses(ctx, items); err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+	if err := h.loadPriceChanges(ctx, items); err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	format := month.FormatFrom(ctx)
+	result := SubscriptionSummary{
+		From:      month.FromTime(from).In(format),
+		To:        month.FromTime(to).In(format),
+		Proration: mode,
+		Months:    []SummaryMonth{},
+	}
+	for _, m := range billing.Months(from, to) {
+		total := billing.Total(items, m, mode)
+		result.Total += total
+		result.Months = append(result.Months, SummaryMonth{Month: month.FromTime(m).In(format), Total: total})
+	}
+
+	if groupBy == "tag" {
+		if result.Tags, err = h.summaryByTag(ctx, items, from, to, mode); err != nil {
+			dbError(ctx, w, err, "")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// reportPeriod разбирает параметры from и to отчёта: по умолчанию — текущий месяц,
+// не больше maxStatusMonths месяцев.
+func reportPeriod(v *validate.Validator, q url.Values) (from, to time.Time) {
+	from = billing.MonthStart(time.Now())
+	if s := q.Get("from"); s != "" {
+		m, err := month.Parse(s)
+		if !v.Check(err == nil, "from", month.ErrFormat.Error()) {
+			return from, from
+		}
+		from = m.Time()
+	}
+	to = from
+	if s := q.Get("to"); s != "" {
+		m, err := month.Parse(s)
+		if !v.Check(err == nil, "to", month.ErrFormat.Error()) {
+			return from, from
+		}
+		to = m.Time()
+	}
+	n := len(billing.Months(from, to))
+	v.Check(n > 0 && n <= maxStatusMonths, "to", fmt.Sprintf("must not be before from and span at most %d months", maxStatusMonths))
+	return from, to
+}
+
+// proration читает режим расчёта неполных месяцев из параметра proration.
+func (h *Handler) proration(v *validate.Validator, q url.Values) billing.Proration {
+	s := q.Get("proration")
+	if s == "" {
+		s = h.Cfg.Billing.Proration
+	}
+	mode, err := billing.ParseProration(s)
+	v.Check(err == nil, "proration", "must be one of none, daily, half_month")
+	return mode
+}
+
+func scanBillingItem(row pgx.CollectableRow) (billing.Item, error) {
+	var it billing.Item
+	err := row.Scan(&it.ID, &it.ServiceName, &it.Price, &it.StartDate, &it.EndDate, &it.TrialEnd, &it.TrialPrice)
+	return it, err
+}
+
+// summaryByTag считает итоги по тегам: сначала теги по алфавиту, затем подписки без тегов.
+func (h *Handler) summaryByTag(ctx context.Context, items []billing.Item, from, to time.Time, mode billing.Proration) ([]SummaryTag, error) {
+	ids := make([]string, len(items))
+	for i, it := range items {
+		ids[i] = it.ID
+	}
+	tags, err := h.loadTags(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]billing.Item)
+	var untagged []billing.Item
+	for _, it := range items {
+		if len(tags[it.ID]) == 0 {
+			untagged = append(untagged, it)
+		}
+		for _, tag := range tags[it.ID] {
+			groups[tag] = append(groups[tag], it)
+		}
+	}
+	names := slices.Sorted(maps.Keys(groups))
+
+	format := month.FormatFrom(ctx)
+	total := func(tag *string, items []billing.Item) SummaryTag {
+		st := SummaryTag{Tag: tag, Months: []SummaryMonth{}}
+		for _, m := range billing.Months(from, to) {
+			t := billing.Total(items, m, mode)
+			st.Total += t
+			st.Months = append(st.Months, SummaryMonth{Month: month.FromTime(m).In(format), Total: t})
+		}
+		return st
+	}
+
+	result := make([]SummaryTag, 0, len(names)+1)
+	for _, name := range names {
+		result = append(result, total(&name, groups[name]))
+	}
+	if len(untagged) > 0 {
+		result = append(result, total(nil, untagged))
+	}
+	return result, nil
+}
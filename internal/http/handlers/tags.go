@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"SubServices/internal/http/problem"
+	"SubServices/internal/http/validate"
+	"SubServices/internal/month"
+	"SubServices/internal/policy"
+	"SubServices/internal/storage"
+)
+
+const (
+	// maxTagLen соответствует tags.name VARCHAR(64).
+	maxTagLen = 64
+	// maxTagsPerRequest ограничивает число тегов в одном запросе и в фильтре списка.
+	maxTagsPerRequest = 20
+)
+
+type TagsRequest struct {
+	Tags []string `json:"tags" example:"entertainment,dev tools"`
+}
+
+// normalizeTag приводит тег к виду, в котором он хранится: нижний регистр, одиночные пробелы.
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+// parseTags нормализует теги и убирает повторы; field — имя поля в ошибках.
+func parseTags(v *validate.Validator, field string, tags []string) []string {
+	if !v.Check(len(tags) > 0, field, "must contain at least one tag") ||
+		!v.Check(len(tags) <= maxTagsPerRequest, field, fmt.Sprintf("must contain at most %d tags", maxTagsPerRequest)) {
+		return nil
+	}
+	names := make([]string, 0, len(tags))
+	for i, tag := range tags {
+		name := normalizeTag(tag)
+		f := fmt.Sprintf("%s[%d]", field, i)
+		if !v.Required(f, name) || !v.MaxLen(f, name, maxTagLen) {
+			continue
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// tagsColumn — теги подписки в алфавитном порядке; входит в subscriptionColumns.
+const tagsColumn = `ARRAY(
+		SELECT t.name FROM subscription_tags st
+		JOIN tags t ON t.id = st.tag_id
+		WHERE st.subscription_id = subscriptions.id
+		ORDER BY t.name
+	)`
+
+// taggedWith — условие на теги подписки: хотя бы один из $param или, если $all, все.
+func taggedWith(param, all string) string {
+	return `(
+			SELECT count(*) FROM subscription_tags st
+			JOIN tags t ON t.id = st.tag_id
+			WHERE st.subscription_id = subscriptions.id AND t.name = ANY(` + param + `)
+		) >= CASE WHEN ` + all + ` THEN cardinality(` + param + `) ELSE 1 END`
+}
+
+// AddTags godoc
+// @Summary Добавить теги подписке
+// @Description Теги сравниваются без учёта регистра и лишних пробелов; новые теги создаются автоматически,
+// @Description уже назначенные пропускаются
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "ID подписки"
+// @Param tags body TagsRequest true "Теги"
+// @Param date_format query string false "Формат дат ответа: mm-yyyy (по умолчанию), yyyy-mm, yyyy-mm-dd, rfc3339"
+// @Success 200 {object} Subscription
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /subscriptions/{id}/tags [post]
+func (h *Handler) AddTags(w http.ResponseWriter, r *http.Request) {
+	h.changeTags(w, r, func(ctx context.Context, tx pgx.Tx, id string, names []string) error {
+		query := `
+			INSERT INTO tags (id, name)
+			SELECT gen_random_uuid(), name FROM unnest($1::text[]) AS name
+			ON CONFLICT (name) DO NOTHING
+		`
+		if _, err := tx.Exec(ctx, query, names); err != nil {
+			return err
+		}
+		query = `
+			INSERT INTO subscription_tags (subscription_id, tag_id)
+			SELECT $1, id FROM tags WHERE name = ANY($2)
+			ON CONFLICT DO NOTHING
+		`
+		_, err := tx.Exec(ctx, query, id, names)
+		return err
+	})
+}
+
+// RemoveTags godoc
+// @Summary Снять теги с подписки
+// @Description Теги, не назначенные подписке, пропускаются; сами теги остаются доступными для других подписок
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "ID подписки"
+// @Param tags body TagsRequest true "Теги"
+// @Param date_format query string false "Формат дат ответа: mm-yyyy (по умолчанию), yyyy-mm, yyyy-mm-dd, rfc3339"
+// @Success 200 {object} Subscription
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /subscriptions/{id}/tags [delete]
+func (h *Handler) RemoveTags(w http.ResponseWriter, r *http.Request) {
+	h.changeTags(w, r, func(ctx context.Context, tx pgx.Tx, id string, names []string) error {
+		query := `
+			DELETE FROM subscription_tags
+			WHERE subscription_id = $1
+			AND tag_id IN (SELECT id FROM tags WHERE name = ANY($2))
+		`
+		_, err := tx.Exec(ctx, query, id, names)
+		return err
+	})
+}
+
+// changeTags разбирает TagsRequest, выполняет change над подпиской из URL и отвечает её новым состоянием.
+func (h *Handler) changeTags(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, tx pgx.Tx, id string, names []string) error) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var req TagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Send(ctx, w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	var v validate.Validator
+	names := parseTags(&v, "tags", req.Tags)
+	if err := v.Err(); err != nil {
+		problem.Validation(ctx, w, err)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	var s Subscription
+	err := storage.InTx(ctx, h.DB, func(tx pgx.Tx) error {
+		locked, err := lockSubscription(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := h.Policy.Authorize(ctx, policy.Write, locked.UserID); err != nil {
+			return err
+		}
+		if err := change(ctx, tx, id, names); err != nil {
+			return err
+		}
+		s, err = lockSubscription(ctx, tx, id)
+		return err
+	})
+	if errors.Is(err, policy.ErrForbidden) {
+		problem.Send(ctx, w, http.StatusForbidden, "forbidden")
+		return
+	}
+	if err != nil {
+		dbError(ctx, w, err, "Subscription not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.In(month.FormatFrom(ctx)))
+}
+
+// loadTags возвращает теги подписок ids.
+func (h *Handler) loadTags(ctx context.Context, ids []string) (map[string][]string, error) {
+	query := `
+		SELECT st.subscription_id, t.name
+		FROM subscription_tags st
+		JOIN tags t ON t.id = st.tag_id
+		WHERE st.subscription_id = ANY($1)
+		ORDER BY t.name
+	`
+	rows, err := h.DB.Query(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]string)
+	var subscriptionID, name string
+	_, err = pgx.ForEachRow(rows, []any{&subscriptionID, &name}, func() error {
+		result[subscriptionID] = append(result[subscriptionID], name)
+		return nil
+	})
+	return result, err
+}
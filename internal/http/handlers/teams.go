@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"SubServices/internal/http/problem"
+	"SubServices/internal/policy"
+)
+
+type TeamRequest struct {
+	Name string `json:"name"`
+}
+
+type Team struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Members   []string  `json:"members"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateTeam godoc
+// @Summary Создать команду
+// @Description Команды задают, чьи данные видит пользователь с ролью manager: он читает подписки
+// @Description и бюджеты всех участников команд, в которых состоит сам
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Param team body TeamRequest true "Данные команды"
+// @Success 201 {object} map[string]string
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /teams [post]
+func (h *Handler) CreateTeam(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !h.authorize(ctx, w, policy.Manage, "") {
+		return
+	}
+
+	var req TeamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Send(ctx, w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 255 {
+		problem.Validation(ctx, w, problem.Invalid("name", "must be between 1 and 255 characters"))
+		return
+	}
+
+	id := uuid.New().String()
+	_, err := h.DB.Exec(ctx, `INSERT INTO teams (id, name) VALUES ($1, $2)`, id, req.Name)
+	if isUniqueViolation(err) {
+		problem.Send(ctx, w, http.StatusConflict, "Team already exists")
+		return
+	}
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"id": id})
+}
+
+// ListTeams godoc
+// @Summary Получить команды
+// @Tags teams
+// @Produce json
+// @Success 200 {array} Team
+// @Failure 403 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /teams [get]
+func (h *Handler) ListTeams(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !h.authorize(ctx, w, policy.Manage, "") {
+		return
+	}
+
+	query := `
+		SELECT t.id, t.name, t.created_at,
+		       COALESCE(array_agg(m.user_id::text ORDER BY m.user_id) FILTER (WHERE m.user_id IS NOT NULL), '{}')
+		FROM teams t
+		LEFT JOIN team_members m ON m.team_id = t.id
+		GROUP BY t.id
+		ORDER BY t.name
+	`
+	rows, err := h.DB.Query(ctx, query)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	result, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Team, error) {
+		var t Team
+		err := row.Scan(&t.ID, &t.Name, &t.CreatedAt, &t.Members)
+		return t, err
+	})
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// DeleteTeam godoc
+// @Summary Удалить команду
+// @Tags teams
+// @Param id path string true "ID команды"
+// @Success 204
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /teams/{id} [delete]
+func (h *Handler) DeleteTeam(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !h.authorize(ctx, w, policy.Manage, "") {
+		return
+	}
+
+	tag, err := h.DB.Exec(ctx, `DELETE FROM teams WHERE id = $1`, chi.URLParam(r, "id"))
+	if err != nil {
+		dbError(ctx, w, err, "Team not found")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		problem.Send(ctx, w, http.StatusNotFound, "Team not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddTeamMember godoc
+// @Summary Добавить пользователя в команду
+// @Tags teams
+// @Param id path string true "ID команды"
+// @Param user_id path string true "ID пользователя"
+// @Success 204
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /teams/{id}/members/{user_id} [put]
+func (h *Handler) AddTeamMember(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, ok := h.userIDParam(w, r, policy.Manage)
+	if !ok {
+		return
+	}
+
+	query := `INSERT INTO team_members (team_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	_, err := h.DB.Exec(ctx, query, chi.URLParam(r, "id"), userID)
+	if isForeignKeyViolation(err) {
+		problem.Send(ctx, w, http.StatusNotFound, "Team or user not found")
+		return
+	}
+	if err != nil {
+		dbError(ctx, w, err, "Team not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveTeamMember godoc
+// @Summary Исключить пользователя из команды
+// @Tags teams
+// @Param id path string true "ID команды"
+// @Param user_id path string true "ID пользователя"
+// @Success 204
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /teams/{id}/members/{user_id} [delete]
+func (h *Handler) RemoveTeamMember(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, ok := h.userIDParam(w, r, policy.Manage)
+	if !ok {
+		return
+	}
+
+	tag, err := h.DB.Exec(ctx, `DELETE FROM team_members WHERE team_id = $1 AND user_id = $2`, chi.URLParam(r, "id"), userID)
+	if err != nil {
+		dbError(ctx, w, err, "Team member not found")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		problem.Send(ctx, w, http.StatusNotFound, "Team member not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"SubServices/internal/http/problem"
+	"SubServices/internal/http/validate"
+	"SubServices/internal/lifecycle"
+	"SubServices/internal/month"
+	"SubServices/internal/policy"
+	"SubServices/internal/storage"
+)
+
+// TransitionRequest переводит подписку в статус To. Для перехода в cancelled
+// используются поля CancelRequest.
+type TransitionRequest struct {
+	To string `json:"to" example:"paused"`
+	CancelRequest
+}
+
+var errManualStatus = errors.New("status is set automatically")
+
+// resultError — действие перевело бы подписку не в запрошенный статус,
+// например возобновление во время пробного периода даёт trial, а не active.
+type resultError struct {
+	want, got lifecycle.Status
+}
+
+func (e *resultError) Error() string {
+	return fmt.Sprintf("subscription would move to %s, not %s", e.got, e.want)
+}
+
+// TransitionSubscription godoc
+// @Summary Перевести подписку в другой статус
+// @Description Переход выполняется действием над подпиской и проверяется по таблице переходов:
+// @Description paused — пауза с сегодняшнего дня без даты окончания;
+// @Description trial или active из paused — возобновление с сегодняшнего дня, из scheduled — начало подписки сегодня,
+// @Description active из trial — окончание пробного периода вчера, из cancelled — снятие отмены, подписка становится бессрочной;
+// @Description cancelled — отмена с reason, comment и effective, как в /cancel.
+// @Description scheduled и expired выставляются только автоматически. Если действие приводит к другому статусу, возвращается 409
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "ID подписки"
+// @Param transition body TransitionRequest true "Целевой статус"
+// @Param date_format query string false "Формат дат ответа: mm-yyyy (по умолчанию), yyyy-mm, yyyy-mm-dd, rfc3339"
+// @Success 200 {object} Subscription
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /subscriptions/{id}/transitions [post]
+func (h *Handler) TransitionSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var req TransitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Send(ctx, w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	var v validate.Validator
+	to, err := lifecycle.ParseStatus(req.To)
+	v.Check(err == nil, "to", "must be one of scheduled, trial, active, paused, cancelled, expired")
+	var end month.Date
+	if to == lifecycle.Cancelled {
+		end = req.validate(&v)
+	}
+	if err := v.Err(); err != nil {
+		problem.Validation(ctx, w, err)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	var s Subscription
+	err = storage.InTx(ctx, h.DB, func(tx pgx.Tx) error {
+		locked, err := lockSubscription(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := h.Policy.Authorize(ctx, policy.Write, locked.UserID); err != nil {
+			return err
+		}
+
+		// Сначала применяются наступившие по времени переходы, которые фоновая задача ещё не выполнила.
+		// Sync мог сменить статус (например, trial → active), поэтому переход проверяется
+		// по перечитанной строке, а не по прочитанной до него.
+		if _, err := lifecycle.Sync(ctx, tx, id); err != nil {
+			return err
+		}
+		current, err := lockSubscription(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		from := current.Status
+		if !lifecycle.CanTransition(from, to) || from == to {
+			return &lifecycle.TransitionError{From: from, To: to}
+		}
+		if err := transition(ctx, tx, current, from, to, req.CancelRequest, end); err != nil {
+			return err
+		}
+
+		got, err := lifecycle.Sync(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if got != to {
+			return &resultError{want: to, got: got}
+		}
+		s, err = lockSubscription(ctx, tx, id)
+		return err
+	})
+	var (
+		terr *lifecycle.TransitionError
+		rerr *resultError
+	)
+	switch {
+	case errors.Is(err, policy.ErrForbidden):
+		problem.Send(ctx, w, http.StatusForbidden, "forbidden")
+		return
+	case errors.As(err, &terr), errors.As(err, &rerr),
+		errors.Is(err, errManualStatus), errors.Is(err, errPauseOverlaps), errors.Is(err, errPauseOutsidePeriod),
+		errors.Is(err, errNotPaused), errors.Is(err, lifecycle.ErrNotStarted), errors.Is(err, lifecycle.ErrAlreadyCancelled):
+		problem.Send(ctx, w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		dbError(ctx, w, err, "Subscription not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.In(month.FormatFrom(ctx)))
+}
+
+// transition выполняет действие, переводящее подписку s из статуса from в to.
+func transition(ctx context.Context, tx pgx.Tx, s Subscription, from, to lifecycle.Status, cancel CancelRequest, end month.Date) error {
+	if to == lifecycle.Scheduled || to == lifecycle.Expired {
+		return errManualStatus
+	}
+	today := month.DateFromTime(time.Now())
+
+	switch {
+	case to == lifecycle.Paused:
+		p := Pause{ID: uuid.New().String(), SubscriptionID: s.ID, From: today}
+		return insertPause(ctx, tx, s, &p)
+
+	case to == lifecycle.Cancelled:
+		_, err := cancelSubscription(ctx, tx, s, cancel, end)
+		return err
+
+	case from == lifecycle.Paused:
+		_, err := resumeAt(ctx, tx, s.ID, today)
+		return err
+
+	case from == lifecycle.Scheduled:
+		_, err := tx.Exec(ctx, `UPDATE subscriptions SET start_date = $1 WHERE id = $2`, today, s.ID)
+		return err
+
+	case from == lifecycle.Trial:
+		query := `
+			UPDATE subscriptions
+			SET trial_end = CASE WHEN start_date < $1::date THEN $1::date - 1 END,
+			    trial_price = CASE WHEN start_date < $1::date THEN trial_price END
+			WHERE id = $2
+		`
+		_, err := tx.Exec(ctx, query, today, s.ID)
+		return err
+
+	case from == lifecycle.Cancelled:
+		query := `
+			UPDATE subscriptions
+			SET end_date = NULL, cancel_reason = NULL, cancel_comment = NULL, cancelled_at = NULL
+			WHERE id = $1
+		`
+		_, err := tx.Exec(ctx, query, s.ID)
+		return err
+	}
+
+	return errManualStatus
+}
@@ -0,0 +1,375 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"SubServices/internal/config"
+	"SubServices/internal/events"
+	"SubServices/internal/http/problem"
+	"SubServices/internal/http/validate"
+	"SubServices/internal/outbox"
+	"SubServices/internal/policy"
+	"SubServices/internal/storage"
+)
+
+// maxDisplayNameLen соответствует users.display_name VARCHAR(255).
+const maxDisplayNameLen = 255
+
+// errUserHasSubscriptions возвращается при удалении пользователя с подписками
+// в режиме users.delete_policy: reject.
+var errUserHasSubscriptions = errors.New("user has subscriptions")
+
+type UserRequest struct {
+	ID          string  `json:"id,omitempty"`
+	DisplayName string  `json:"display_name"`
+	Email       *string `json:"email,omitempty"`
+	Timezone    string  `json:"timezone,omitempty"`
+}
+
+type User struct {
+	ID          string    `json:"id"`
+	DisplayName string    `json:"display_name"`
+	Email       *string   `json:"email,omitempty"`
+	Timezone    string    `json:"timezone"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CreateUser godoc
+// @Summary Создать пользователя
+// @Description Создаёт пользователя. id можно передать явно, чтобы сохранить идентификатор из внешней системы.
+// @Description timezone — имя из базы IANA, по умолчанию UTC
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param user body UserRequest true "Данные пользователя"
+// @Success 201 {object} map[string]string
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /users [post]
+func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !h.authorize(ctx, w, policy.Manage, "") {
+		return
+	}
+
+	var req UserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Send(ctx, w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if req.ID == "" {
+		req.ID = uuid.New().String()
+	}
+	if err := req.validate(); err != nil {
+		problem.Validation(ctx, w, err)
+		return
+	}
+
+	query := `INSERT INTO users (id, display_name, email, timezone) VALUES ($1, $2, $3, $4)`
+	_, err := h.DB.Exec(ctx, query, req.ID, req.DisplayName, req.Email, req.Timezone)
+	if isUniqueViolation(err) {
+		problem.Send(ctx, w, http.StatusConflict, "User with this id or email already exists")
+		return
+	}
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"id": req.ID})
+}
+
+// ListUsers godoc
+// @Summary Получить пользователей
+// @Description Список ограничен ролью вызывающего: user видит себя, manager — пользователей своих команд
+// @Tags users
+// @Produce json
+// @Success 200 {array} User
+// @Failure 403 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /users [get]
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	visible, ok := h.visibleUsers(ctx, w)
+	if !ok {
+		return
+	}
+
+	query := `
+		SELECT id, display_name, email, timezone, created_at
+		FROM users
+		WHERE ($1::uuid[] IS NULL OR id = ANY($1))
+		ORDER BY created_at
+	`
+	rows, err := h.DB.Query(ctx, query, visible)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	result, err := pgx.CollectRows(rows, scanUser)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetUser godoc
+// @Summary Получить пользователя
+// @Tags users
+// @Produce json
+// @Param user_id path string true "ID пользователя"
+// @Success 200 {object} User
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /users/{user_id} [get]
+func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, ok := h.userIDParam(w, r, policy.Read)
+	if !ok {
+		return
+	}
+
+	query := `SELECT id, display_name, email, timezone, created_at FROM users WHERE id = $1`
+	rows, _ := h.DB.Query(ctx, query, userID)
+	u, err := pgx.CollectExactlyOneRow(rows, scanUser)
+	if err != nil {
+		dbError(ctx, w, err, "User not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(u)
+}
+
+// UpdateUser godoc
+// @Summary Обновить пользователя
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param user_id path string true "ID пользователя"
+// @Param user body UserRequest true "Данные пользователя"
+// @Success 200 {object} User
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /users/{user_id} [put]
+func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, ok := h.userIDParam(w, r, policy.Write)
+	if !ok {
+		return
+	}
+
+	var req UserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Send(ctx, w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	req.ID = userID
+	if err := req.validate(); err != nil {
+		problem.Validation(ctx, w, err)
+		return
+	}
+
+	query := `
+		UPDATE users
+		SET display_name=$1, email=$2, timezone=$3
+		WHERE id=$4
+		RETURNING id, display_name, email, timezone, created_at
+	`
+	rows, _ := h.DB.Query(ctx, query, req.DisplayName, req.Email, req.Timezone, userID)
+	u, err := pgx.CollectExactlyOneRow(rows, scanUser)
+	if isUniqueViolation(err) {
+		problem.Send(ctx, w, http.StatusConflict, "User with this email already exists")
+		return
+	}
+	if err != nil {
+		dbError(ctx, w, err, "User not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(u)
+}
+
+// DeleteUser godoc
+// @Summary Удалить пользователя
+// @Description Удаляет пользователя и его бюджеты. Если у пользователя есть подписки, поведение задаётся
+// @Description users.delete_policy: reject — 409, cascade — подписки удаляются вместе с пользователем
+// @Tags users
+// @Param user_id path string true "ID пользователя"
+// @Success 204
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /users/{user_id} [delete]
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, ok := h.userIDParam(w, r, policy.Write)
+	if !ok {
+		return
+	}
+
+	err := storage.InTx(ctx, h.DB, func(tx pgx.Tx) error {
+		if h.Cfg.Users.DeletePolicy == config.UserDeletePolicyCascade {
+			if err := deleteUserSubscriptions(ctx, tx, userID); err != nil {
+				return err
+			}
+		}
+
+		tag, err := tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, userID)
+		if isForeignKeyViolation(err) {
+			return errUserHasSubscriptions
+		}
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		problem.Send(ctx, w, http.StatusNotFound, "User not found")
+		return
+	case errors.Is(err, errUserHasSubscriptions):
+		problem.Send(ctx, w, http.StatusConflict, "User has subscriptions")
+		return
+	case err != nil:
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListUserSubscriptions godoc
+// @Summary Получить подписки пользователя
+// @Tags users
+// @Produce json
+// @Param user_id path string true "ID пользователя"
+// @Param date_format query string false "Формат дат ответа: mm-yyyy (по умолчанию), yyyy-mm, yyyy-mm-dd, rfc3339"
+// @Success 200 {array} Subscription
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /users/{user_id}/subscriptions [get]
+func (h *Handler) ListUserSubscriptions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, ok := h.userIDParam(w, r, policy.Read)
+	if !ok {
+		return
+	}
+
+	var exists bool
+	if err := h.DB.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists); err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+	if !exists {
+		problem.Send(ctx, w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE user_id = $1 ORDER BY start_date`
+	result, err := h.querySubscriptions(ctx, query, userID)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// deleteUserSubscriptions удаляет подписки пользователя, записывая в outbox событие
+// удаления для каждой из них.
+func deleteUserSubscriptions(ctx context.Context, tx pgx.Tx, userID string) error {
+	rows, err := tx.Query(ctx, `DELETE FROM subscriptions WHERE user_id = $1 RETURNING id, service_name`, userID)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (map[string]string, error) {
+		var id, serviceName string
+		err := row.Scan(&id, &serviceName)
+		return map[string]string{"id": id, "user_id": userID, "service_name": serviceName}, err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, data := range deleted {
+		if err := outbox.Write(ctx, tx, events.SubscriptionDeleted, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanUser(row pgx.CollectableRow) (User, error) {
+	var u User
+	err := row.Scan(&u.ID, &u.DisplayName, &u.Email, &u.Timezone, &u.CreatedAt)
+	return u, err
+}
+
+// validate нормализует поля запроса и возвращает все найденные ошибки разом.
+func (r *UserRequest) validate() error {
+	var v validate.Validator
+
+	v.UUID("id", r.ID)
+
+	r.DisplayName = strings.TrimSpace(r.DisplayName)
+	v.MaxLen("display_name", r.DisplayName, maxDisplayNameLen)
+
+	if r.Email != nil {
+		addr, err := mail.ParseAddress(*r.Email)
+		v.Check(err == nil && addr.Name == "", "email", "must be a bare email address")
+	}
+
+	if r.Timezone == "" {
+		r.Timezone = "UTC"
+	}
+	_, err := time.LoadLocation(r.Timezone)
+	v.Check(err == nil, "timezone", "must be an IANA time zone name")
+
+	return v.Err()
+}
@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"SubServices/internal/events"
+	"SubServices/internal/http/problem"
+	"SubServices/internal/policy"
+	"SubServices/internal/webhooks"
+)
+
+const maxDeliveriesLimit = 500
+
+type WebhookCreateRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret,omitempty"`
+}
+
+type WebhookUpdateRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Active bool     `json:"active"`
+}
+
+type WebhookCreateResponse struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+}
+
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastError      *string         `json:"last_error,omitempty"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// CreateWebhook godoc
+// @Summary Зарегистрировать webhook
+// @Description Регистрирует эндпоинт для событий subscription.created|updated|deleted|ending.
+// @Description Тело каждой доставки подписывается HMAC-SHA256 секретом эндпоинта, подпись передаётся
+// @Description в заголовке X-Webhook-Signature в виде t=<unix>,v1=<hex>. Если secret не передан, он генерируется
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param webhook body WebhookCreateRequest true "Данные webhook"
+// @Success 201 {object} WebhookCreateResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /webhooks [post]
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !h.authorize(ctx, w, policy.Manage, "") {
+		return
+	}
+
+	var req WebhookCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Send(ctx, w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if err := validateWebhook(req.URL, req.Events); err != nil {
+		problem.Validation(ctx, w, err)
+		return
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		buf := make([]byte, 32)
+		rand.Read(buf)
+		secret = hex.EncodeToString(buf)
+	}
+
+	id := uuid.New().String()
+	query := `INSERT INTO webhook_endpoints (id, url, secret, events) VALUES ($1, $2, $3, $4)`
+	if _, err := h.DB.Exec(ctx, query, id, req.URL, secret, req.Events); err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(WebhookCreateResponse{ID: id, Secret: secret})
+}
+
+// ListWebhooks godoc
+// @Summary Получить webhook'и
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} Webhook
+// @Failure 403 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /webhooks [get]
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !h.authorize(ctx, w, policy.Manage, "") {
+		return
+	}
+
+	query := `SELECT id, url, events, active, created_at FROM webhook_endpoints ORDER BY created_at`
+	rows, err := h.DB.Query(ctx, query)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	result, err := pgx.CollectRows(rows, scanWebhook)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetWebhook godoc
+// @Summary Получить webhook
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "ID webhook"
+// @Success 200 {object} Webhook
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /webhooks/{id} [get]
+func (h *Handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !h.authorize(ctx, w, policy.Manage, "") {
+		return
+	}
+
+	query := `SELECT id, url, events, active, created_at FROM webhook_endpoints WHERE id = $1`
+	rows, _ := h.DB.Query(ctx, query, chi.URLParam(r, "id"))
+	wh, err := pgx.CollectExactlyOneRow(rows, scanWebhook)
+	if err != nil {
+		dbError(ctx, w, err, "Webhook not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(wh)
+}
+
+// UpdateWebhook godoc
+// @Summary Обновить webhook
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "ID webhook"
+// @Param webhook body WebhookUpdateRequest true "Данные webhook"
+// @Success 200 {object} Webhook
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /webhooks/{id} [put]
+func (h *Handler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !h.authorize(ctx, w, policy.Manage, "") {
+		return
+	}
+
+	var req WebhookUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Send(ctx, w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if err := validateWebhook(req.URL, req.Events); err != nil {
+		problem.Validation(ctx, w, err)
+		return
+	}
+
+	query := `
+		UPDATE webhook_endpoints
+		SET url=$1, events=$2, active=$3
+		WHERE id=$4
+		RETURNING id, url, events, active, created_at
+	`
+	rows, _ := h.DB.Query(ctx, query, req.URL, req.Events, req.Active, chi.URLParam(r, "id"))
+	wh, err := pgx.CollectExactlyOneRow(rows, scanWebhook)
+	if err != nil {
+		dbError(ctx, w, err, "Webhook not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(wh)
+}
+
+// DeleteWebhook godoc
+// @Summary Удалить webhook
+// @Description Удаляет эндпоинт вместе с журналом доставок
+// @Tags webhooks
+// @Param id path string true "ID webhook"
+// @Success 204
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /webhooks/{id} [delete]
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !h.authorize(ctx, w, policy.Manage, "") {
+		return
+	}
+
+	tag, err := h.DB.Exec(ctx, `DELETE FROM webhook_endpoints WHERE id = $1`, chi.URLParam(r, "id"))
+	if err != nil {
+		dbError(ctx, w, err, "Webhook not found")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		problem.Send(ctx, w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries godoc
+// @Summary Журнал доставок webhook
+// @Description Последние доставки эндпоинта, новые первыми
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "ID webhook"
+// @Param status query string false "Статус доставки (pending, delivered, dead)"
+// @Param limit query int false "Количество записей (по умолчанию 100)"
+// @Success 200 {array} WebhookDelivery
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /webhooks/{id}/deliveries [get]
+func (h *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !h.authorize(ctx, w, policy.Manage, "") {
+		return
+	}
+
+	q := r.URL.Query()
+
+	status := q.Get("status")
+	switch status {
+	case "", webhooks.StatusPending, webhooks.StatusDelivered, webhooks.StatusDead:
+	default:
+		problem.Validation(ctx, w, problem.Invalid("status", "must be one of pending, delivered, dead"))
+		return
+	}
+
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxDeliveriesLimit {
+			problem.Validation(ctx, w, problem.Invalid("limit", "must be between 1 and 500"))
+			return
+		}
+		limit = n
+	}
+
+	query := `
+		SELECT id, event_id, event_type, payload, status, attempts, next_attempt_at,
+		       last_error, response_status, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE endpoint_id = $1
+		AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+		LIMIT $3
+	`
+	rows, err := h.DB.Query(ctx, query, chi.URLParam(r, "id"), status, limit)
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	result, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (WebhookDelivery, error) {
+		var d WebhookDelivery
+		err := row.Scan(&d.ID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastError, &d.ResponseStatus, &d.CreatedAt, &d.DeliveredAt)
+		return d, err
+	})
+	if err != nil {
+		dbError(ctx, w, err, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// RedeliverWebhook godoc
+// @Summary Повторить доставку
+// @Description Возвращает доставку (в том числе из статуса dead) в очередь с обнулённым счётчиком попыток
+// @Tags webhooks
+// @Param id path string true "ID webhook"
+// @Param delivery_id path string true "ID доставки"
+// @Success 202
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Router /webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func (h *Handler) RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !h.authorize(ctx, w, policy.Manage, "") {
+		return
+	}
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = now()
+		WHERE id = $1 AND endpoint_id = $2
+	`
+	tag, err := h.DB.Exec(ctx, query, chi.URLParam(r, "delivery_id"), chi.URLParam(r, "id"))
+	if err != nil {
+		dbError(ctx, w, err, "Delivery not found")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		problem.Send(ctx, w, http.StatusNotFound, "Delivery not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func scanWebhook(row pgx.CollectableRow) (Webhook, error) {
+	var wh Webhook
+	err := row.Scan(&wh.ID, &wh.URL, &wh.Events, &wh.Active, &wh.CreatedAt)
+	return wh, err
+}
+
+func validateWebhook(rawURL string, eventTypes []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return problem.Invalid("url", "must be an absolute http or https URL")
+	}
+	if len(eventTypes) == 0 {
+		return problem.Invalid("events", "must not be empty")
+	}
+	for _, t := range eventTypes {
+		if !events.IsKnownType(t) {
+			return problem.Invalid("events", fmt.Sprintf("unknown event %q", t))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+// Package problem формирует ответы об ошибках в формате RFC 7807
+// (application/problem+json).
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const ContentType = "application/problem+json"
+
+// TypeBase — префикс URI типов ошибок; типы описаны в docs/problems.md.
+const TypeBase = "https://github.com/Truncklin/subscriptions-to-services/blob/main/docs/problems.md#"
+
+const (
+	TypeBadRequest         = TypeBase + "bad-request"
+	TypeValidation         = TypeBase + "validation-error"
+	TypeUnauthorized       = TypeBase + "unauthorized"
+	TypeForbidden          = TypeBase + "forbidden"
+	TypeNotFound           = TypeBase + "not-found"
+	TypeConflict           = TypeBase + "conflict"
+	TypeBudgetExceeded     = TypeBase + "budget-exceeded"
+	TypeRateLimited        = TypeBase + "rate-limited"
+	TypeInternal           = TypeBase + "internal-error"
+	TypeServiceUnavailable = TypeBase + "service-unavailable"
+)
+
+var defaultTypes = map[int]string{
+	http.StatusBadRequest:          TypeBadRequest,
+	http.StatusUnauthorized:        TypeUnauthorized,
+	http.StatusForbidden:           TypeForbidden,
+	http.StatusNotFound:            TypeNotFound,
+	http.StatusConflict:            TypeConflict,
+	http.StatusTooManyRequests:     TypeRateLimited,
+	http.StatusInternalServerError: TypeInternal,
+	http.StatusServiceUnavailable:  TypeServiceUnavailable,
+}
+
+// Problem — тело ответа об ошибке.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError — ошибка в конкретном поле тела, параметре пути или запроса.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// FieldErrors — несколько ошибок полей, о которых нужно сообщить разом.
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Invalid возвращает ошибку поля field.
+func Invalid(field, message string) error {
+	return FieldError{Field: field, Message: message}
+}
+
+// New создаёт Problem с типом по умолчанию для status.
+func New(ctx context.Context, status int, detail string) Problem {
+	typ, ok := defaultTypes[status]
+	if !ok {
+		typ = "about:blank"
+	}
+	return Problem{
+		Type:      typ,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		RequestID: middleware.GetReqID(ctx),
+	}
+}
+
+// Write отправляет body со статусом status. body — Problem или структура,
+// встраивающая Problem и добавляющая поля-расширения.
+func Write(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// Send отправляет Problem со статусом status и описанием detail.
+func Send(ctx context.Context, w http.ResponseWriter, status int, detail string) {
+	Write(w, status, New(ctx, status, detail))
+}
+
+// Validation отправляет 400 с перечнем ошибок полей из err. Ошибки, не
+// относящиеся к полям, попадают в detail.
+func Validation(ctx context.Context, w http.ResponseWriter, err error) {
+	p := New(ctx, http.StatusBadRequest, "request validation failed")
+	p.Type = TypeValidation
+
+	var (
+		fe  FieldError
+		fes FieldErrors
+	)
+	switch {
+	case errors.As(err, &fes):
+		p.Errors = fes
+	case errors.As(err, &fe):
+		p.Errors = []FieldError{fe}
+	default:
+		p.Detail = err.Error()
+	}
+	Write(w, http.StatusBadRequest, p)
+}
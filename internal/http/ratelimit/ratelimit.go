@@ -0,0 +1,183 @@
+// Package ratelimit ограничивает частоту запросов каждого клиента алгоритмом
+// token bucket. Клиент определяется по API-ключу, subject JWT или IP-адресу.
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"SubServices/internal/config"
+	"SubServices/internal/http/auth"
+	"SubServices/internal/http/problem"
+)
+
+// sweepInterval — как часто из памяти удаляются полностью восстановившиеся корзины.
+const sweepInterval = time.Minute
+
+// Finder возвращает шаблон маршрута chi (например, /api/subscriptions/{id}) для запроса.
+type Finder func(method, path string) string
+
+type limit struct {
+	rate  float64
+	burst float64
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  limit
+}
+
+// Limiter хранит корзины клиентов в памяти экземпляра. Маршруты без собственного
+// лимита в конфиге расходуют общую корзину клиента.
+type Limiter struct {
+	def    limit
+	routes map[string]limit
+	find   Finder
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New создаёт Limiter или возвращает nil, если ограничение выключено.
+func New(cfg config.RateLimitConfig, find Finder) *Limiter {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	l := &Limiter{
+		def:     newLimit(cfg.Rate, cfg.Burst),
+		routes:  make(map[string]limit, len(cfg.Routes)),
+		find:    find,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+	for route, rl := range cfg.Routes {
+		l.routes[normalizeRoute(route)] = newLimit(rl.Rate, rl.Burst)
+	}
+	return l
+}
+
+// Middleware должен стоять после аутентификации, чтобы различать клиентов
+// по ключу и subject, а не только по IP.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := clientKey(r)
+		lim := l.def
+		if len(l.routes) > 0 {
+			route := normalizeRoute(r.Method + " " + l.find(r.Method, r.URL.Path))
+			if rl, ok := l.routes[route]; ok {
+				key += "|" + route
+				lim = rl
+			}
+		}
+
+		allowed, remaining, reset, retryAfter := l.take(key, lim)
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(int(lim.burst)))
+		h.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(seconds(reset)))
+
+		if !allowed {
+			h.Set("Retry-After", strconv.Itoa(seconds(retryAfter)))
+			problem.Send(r.Context(), w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// take списывает токен из корзины key. reset — время до полного восстановления
+// корзины, retryAfter — до появления следующего токена.
+func (l *Limiter) take(key string, lim limit) (allowed bool, remaining int, reset, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) > sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: lim.burst, last: now, limit: lim}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(lim.burst, b.tokens+now.Sub(b.last).Seconds()*lim.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		allowed = true
+	} else {
+		retryAfter = secondsToDuration((1 - b.tokens) / lim.rate)
+	}
+
+	reset = secondsToDuration((lim.burst - b.tokens) / lim.rate)
+	return allowed, int(b.tokens), reset, retryAfter
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		full := b.last.Add(secondsToDuration((b.limit.burst - b.tokens) / b.limit.rate))
+		if now.After(full) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+func clientKey(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok {
+		if p.Method == auth.MethodAPIKey {
+			return "key:" + p.Subject
+		}
+		return "sub:" + p.Subject
+	}
+
+	// middleware.RealIP уже подставил адрес клиента из X-Forwarded-For / X-Real-IP.
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func newLimit(rate float64, burst int) limit {
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	return limit{rate: rate, burst: float64(burst)}
+}
+
+// normalizeRoute приводит "get /api/subscriptions/" и "GET /api/subscriptions"
+// к одному ключу.
+func normalizeRoute(route string) string {
+	method, path, _ := strings.Cut(strings.TrimSpace(route), " ")
+	path = strings.TrimSpace(path)
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return strings.ToUpper(method) + " " + path
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
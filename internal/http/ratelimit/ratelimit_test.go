@@ -0,0 +1,214 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"SubServices/internal/config"
+	"SubServices/internal/http/auth"
+)
+
+// clock — управляемое время для корзин.
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time { return c.t }
+
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(t *testing.T, cfg config.RateLimitConfig) (*Limiter, *clock) {
+	t.Helper()
+	cfg.Enabled = true
+	// Тесты обходятся без chi: шаблон маршрута совпадает с путём.
+	l := New(cfg, func(method, path string) string { return path })
+	c := &clock{t: time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)}
+	l.now = c.now
+	l.lastSweep = c.t
+	return l, c
+}
+
+func request(l *Limiter, method, path string, p *auth.Principal) *httptest.ResponseRecorder {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	r := httptest.NewRequest(method, path, nil)
+	r.RemoteAddr = "192.0.2.1:51234"
+	if p != nil {
+		r = r.WithContext(auth.WithPrincipal(r.Context(), p))
+	}
+	w := httptest.NewRecorder()
+	l.Middleware(ok).ServeHTTP(w, r)
+	return w
+}
+
+func TestBurstExhaustion(t *testing.T) {
+	l, _ := newTestLimiter(t, config.RateLimitConfig{Rate: 1, Burst: 3})
+
+	for i := range 3 {
+		w := request(l, http.MethodGet, "/api/subscriptions", nil)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("request %d: status = %d, want %d", i+1, w.Code, http.StatusNoContent)
+		}
+		if got, want := w.Header().Get("RateLimit-Remaining"), []string{"2", "1", "0"}[i]; got != want {
+			t.Fatalf("request %d: RateLimit-Remaining = %s, want %s", i+1, got, want)
+		}
+	}
+
+	w := request(l, http.MethodGet, "/api/subscriptions", nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	for header, want := range map[string]string{
+		"RateLimit-Limit":     "3",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "3",
+		"Retry-After":         "1",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s = %s, want %s", header, got, want)
+		}
+	}
+}
+
+func TestRefill(t *testing.T) {
+	l, c := newTestLimiter(t, config.RateLimitConfig{Rate: 2, Burst: 2})
+
+	allowed := func() bool {
+		return request(l, http.MethodGet, "/api/subscriptions", nil).Code == http.StatusNoContent
+	}
+
+	if !allowed() || !allowed() || allowed() {
+		t.Fatal("expected two requests within burst, then a rejection")
+	}
+
+	// За 250ms при rate=2 набирается полтокена — этого мало.
+	c.advance(250 * time.Millisecond)
+	if allowed() {
+		t.Fatal("request allowed with half a token")
+	}
+
+	// Ещё 250ms — целый токен.
+	c.advance(250 * time.Millisecond)
+	if !allowed() {
+		t.Fatal("request rejected after refill")
+	}
+	if allowed() {
+		t.Fatal("refill gave more than one token")
+	}
+
+	// Корзина не наполняется выше burst, сколько бы ни прошло времени.
+	c.advance(time.Hour)
+	if !allowed() || !allowed() || allowed() {
+		t.Fatal("refill exceeded burst")
+	}
+}
+
+func TestDefaultBurst(t *testing.T) {
+	// Без burst запас равен rate, округлённому вверх.
+	l, _ := newTestLimiter(t, config.RateLimitConfig{Rate: 1.5})
+
+	if got := request(l, http.MethodGet, "/api/subscriptions", nil).Header().Get("RateLimit-Limit"); got != "2" {
+		t.Fatalf("RateLimit-Limit = %s, want 2", got)
+	}
+}
+
+func TestRouteOverride(t *testing.T) {
+	l, _ := newTestLimiter(t, config.RateLimitConfig{
+		Rate:  10,
+		Burst: 10,
+		Routes: map[string]config.RouteLimit{
+			// Ключ нормализуется: регистр метода и завершающий слеш не важны.
+			"get /api/subscriptions/summary/": {Rate: 1, Burst: 1},
+		},
+	})
+
+	if w := request(l, http.MethodGet, "/api/subscriptions/summary", nil); w.Code != http.StatusNoContent || w.Header().Get("RateLimit-Limit") != "1" {
+		t.Fatalf("first summary request: status = %d, limit = %s", w.Code, w.Header().Get("RateLimit-Limit"))
+	}
+	if w := request(l, http.MethodGet, "/api/subscriptions/summary", nil); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second summary request: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+
+	// Другие маршруты и методы расходуют общую корзину, нетронутую запросами к summary.
+	for _, tc := range []struct{ method, path string }{
+		{http.MethodGet, "/api/subscriptions"},
+		{http.MethodPost, "/api/subscriptions/summary"},
+	} {
+		w := request(l, tc.method, tc.path, nil)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("%s %s: status = %d, want %d", tc.method, tc.path, w.Code, http.StatusNoContent)
+		}
+		if got := w.Header().Get("RateLimit-Limit"); got != "10" {
+			t.Fatalf("%s %s: RateLimit-Limit = %s, want 10", tc.method, tc.path, got)
+		}
+	}
+	if got := request(l, http.MethodGet, "/api/users", nil).Header().Get("RateLimit-Remaining"); got != "7" {
+		t.Fatalf("shared bucket RateLimit-Remaining = %s, want 7", got)
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		principal  *auth.Principal
+		remoteAddr string
+		want       string
+	}{
+		{
+			name:       "api key",
+			principal:  &auth.Principal{Subject: "6f1c", Method: auth.MethodAPIKey, Role: auth.RoleAdmin},
+			remoteAddr: "192.0.2.1:51234",
+			want:       "key:6f1c",
+		},
+		{
+			name:       "jwt subject",
+			principal:  &auth.Principal{Subject: "60601fee", Method: auth.MethodJWT, Role: auth.RoleUser},
+			remoteAddr: "192.0.2.1:51234",
+			want:       "sub:60601fee",
+		},
+		{name: "ip", remoteAddr: "192.0.2.1:51234", want: "ip:192.0.2.1"},
+		{name: "ipv6", remoteAddr: "[2001:db8::1]:51234", want: "ip:2001:db8::1"},
+		// middleware.RealIP подставляет адрес без порта.
+		{name: "ip without port", remoteAddr: "192.0.2.7", want: "ip:192.0.2.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/subscriptions", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.principal != nil {
+				r = r.WithContext(auth.WithPrincipal(r.Context(), tt.principal))
+			}
+			if got := clientKey(r); got != tt.want {
+				t.Fatalf("clientKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientsHaveSeparateBuckets(t *testing.T) {
+	l, _ := newTestLimiter(t, config.RateLimitConfig{Rate: 1, Burst: 1})
+
+	alice := &auth.Principal{Subject: "alice", Method: auth.MethodJWT, Role: auth.RoleUser}
+	key := &auth.Principal{Subject: "alice", Method: auth.MethodAPIKey, Role: auth.RoleAdmin}
+
+	for _, p := range []*auth.Principal{alice, key, nil} {
+		if w := request(l, http.MethodGet, "/api/subscriptions", p); w.Code != http.StatusNoContent {
+			t.Fatalf("first request of %v: status = %d", p, w.Code)
+		}
+	}
+	// Все три корзины исчерпаны независимо друг от друга.
+	for _, p := range []*auth.Principal{alice, key, nil} {
+		if w := request(l, http.MethodGet, "/api/subscriptions", p); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("second request of %v: status = %d", p, w.Code)
+		}
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	if l := New(config.RateLimitConfig{Enabled: false}, nil); l != nil {
+		t.Fatal("New() with enabled=false returned a limiter")
+	}
+}
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"net/http"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -8,28 +9,122 @@ import (
 	httpSwagger "github.com/swaggo/http-swagger"
 
 	_ "SubServices/docs"
+	"SubServices/internal/apikeys"
+	"SubServices/internal/http/auth"
 	"SubServices/internal/http/handlers"
+	"SubServices/internal/http/problem"
+	"SubServices/internal/http/ratelimit"
 )
 
-func InitRouter(h *handlers.Handler) *chi.Mux {
+// InitRouter собирает маршруты API. Если authn == nil, аутентификация отключена.
+// Scope API-ключей проверяются на каждом маршруте, роли — в обработчиках через policy.
+func InitRouter(h *handlers.Handler, authn *auth.Authenticator) *chi.Mux {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
+
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		problem.Send(r.Context(), w, http.StatusNotFound, "route not found")
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		problem.Send(r.Context(), w, http.StatusMethodNotAllowed, "method not allowed")
+	})
+
+	limiter := ratelimit.New(h.Cfg.RateLimit, func(method, path string) string {
+		return r.Find(chi.NewRouteContext(), method, path)
+	})
+
+	read := auth.RequireScope(apikeys.ScopeSubscriptionsRead)
+	write := auth.RequireScope(apikeys.ScopeSubscriptionsWrite)
+	reports := auth.RequireScope(apikeys.ScopeReportsRead)
+	admin := auth.RequireScope(apikeys.ScopeAdmin)
 
 	r.Get("/swagger/*", httpSwagger.WrapHandler)
 
-	r.Route("/api", func(r chi.Router) {
+	// Долгоживущий SSE-поток не должен обрываться по middleware.Timeout.
+	r.With(authn.Middleware, limiter.Middleware, read).Get("/api/subscriptions/stream", h.StreamSubscriptions)
+
+	r.With(middleware.Timeout(60*time.Second)).Route("/api", func(r chi.Router) {
+		r.Use(handlers.DateFormat)
+
 		r.Get("/health", handlers.Health)
-		r.Route("/subscriptions", func(r chi.Router) {
-			r.Post("/", h.CreateSubscription)
-			r.Get("/{id}", h.GetSubscription)
-			r.Put("/{id}", h.UpdateSubscription)
-			r.Delete("/{id}", h.DeleteSubscription)
-			r.Get("/", h.ListSubscriptions)
+
+		r.Group(func(r chi.Router) {
+			r.Use(authn.Middleware)
+			r.Use(limiter.Middleware)
+
+			r.Route("/subscriptions", func(r chi.Router) {
+				r.With(write).Post("/", h.CreateSubscription)
+				r.With(read).Get("/{id}", h.GetSubscription)
+				r.With(write).Put("/{id}", h.UpdateSubscription)
+				r.With(write).Delete("/{id}", h.DeleteSubscription)
+				r.With(write).Post("/{id}/pause", h.PauseSubscription)
+				r.With(write).Post("/{id}/resume", h.ResumeSubscription)
+				r.With(read).Get("/{id}/pauses", h.ListPauses)
+				r.With(write).Post("/{id}/cancel", h.CancelSubscription)
+				r.With(write).Post("/{id}/transitions", h.TransitionSubscription)
+				r.With(read).Get("/{id}/renewals", h.ListRenewals)
+				r.With(write).Post("/{id}/scheduled-changes", h.CreateScheduledChange)
+				r.With(read).Get("/{id}/scheduled-changes", h.ListScheduledChanges)
+				r.With(write).Delete("/{id}/scheduled-changes/{change_id}", h.CancelScheduledChange)
+				r.With(write).Post("/{id}/tags", h.AddTags)
+				r.With(write).Delete("/{id}/tags", h.RemoveTags)
+				r.With(read).Get("/", h.ListSubscriptions)
+				r.With(reports).Get("/summary", h.SubscriptionSummary)
+				r.With(reports).Get("/cancellations", h.CancellationReport)
+			})
+			r.Route("/services", func(r chi.Router) {
+				r.With(read).Get("/", h.ListServices)
+				r.With(read).Get("/{id}", h.GetService)
+				r.With(admin).Post("/", h.CreateService)
+				r.With(admin).Put("/{id}", h.UpdateService)
+				r.With(admin).Delete("/{id}", h.DeleteService)
+			})
+			r.Route("/users", func(r chi.Router) {
+				r.With(admin).Post("/", h.CreateUser)
+				r.With(read).Get("/", h.ListUsers)
+				r.Route("/{user_id}", func(r chi.Router) {
+					r.With(read).Get("/", h.GetUser)
+					r.With(write).Put("/", h.UpdateUser)
+					r.With(write).Delete("/", h.DeleteUser)
+					r.With(read).Get("/subscriptions", h.ListUserSubscriptions)
+					r.Route("/budgets", func(r chi.Router) {
+						r.With(write).Post("/", h.CreateBudget)
+						r.With(read).Get("/", h.ListBudgets)
+						r.With(reports).Get("/status", h.GetBudgetStatus)
+						r.With(read).Get("/{id}", h.GetBudget)
+						r.With(write).Put("/{id}", h.UpdateBudget)
+						r.With(write).Delete("/{id}", h.DeleteBudget)
+					})
+				})
+			})
+			r.Route("/webhooks", func(r chi.Router) {
+				r.Use(admin)
+				r.Post("/", h.CreateWebhook)
+				r.Get("/", h.ListWebhooks)
+				r.Get("/{id}", h.GetWebhook)
+				r.Put("/{id}", h.UpdateWebhook)
+				r.Delete("/{id}", h.DeleteWebhook)
+				r.Get("/{id}/deliveries", h.ListWebhookDeliveries)
+				r.Post("/{id}/deliveries/{delivery_id}/redeliver", h.RedeliverWebhook)
+			})
+			r.Route("/teams", func(r chi.Router) {
+				r.Use(admin)
+				r.Post("/", h.CreateTeam)
+				r.Get("/", h.ListTeams)
+				r.Delete("/{id}", h.DeleteTeam)
+				r.Put("/{id}/members/{user_id}", h.AddTeamMember)
+				r.Delete("/{id}/members/{user_id}", h.RemoveTeamMember)
+			})
+			r.Route("/api-keys", func(r chi.Router) {
+				r.Use(admin)
+				r.Post("/", h.CreateAPIKey)
+				r.Get("/", h.ListAPIKeys)
+				r.Delete("/{id}", h.RevokeAPIKey)
+			})
 		})
 	})
 
@@ -0,0 +1,55 @@
+// Package validate собирает ошибки полей запроса, чтобы клиент узнал обо всех
+// сразу, а не исправлял их по одной.
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"SubServices/internal/http/problem"
+)
+
+type Validator struct {
+	errs problem.FieldErrors
+}
+
+func (v *Validator) Add(field, message string) {
+	v.errs = append(v.errs, problem.FieldError{Field: field, Message: message})
+}
+
+// Check добавляет ошибку, если ok == false, и возвращает ok.
+func (v *Validator) Check(ok bool, field, message string) bool {
+	if !ok {
+		v.Add(field, message)
+	}
+	return ok
+}
+
+// Err возвращает problem.FieldErrors со всеми ошибками или nil.
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+func (v *Validator) Required(field, value string) bool {
+	return v.Check(strings.TrimSpace(value) != "", field, "is required")
+}
+
+// MaxLen проверяет длину в символах, как её считает VARCHAR(n).
+func (v *Validator) MaxLen(field, value string, n int) bool {
+	return v.Check(utf8.RuneCountInString(value) <= n, field, fmt.Sprintf("must be at most %d characters", n))
+}
+
+func (v *Validator) UUID(field, value string) bool {
+	_, err := uuid.Parse(value)
+	return v.Check(err == nil, field, "must be a UUID")
+}
+
+func (v *Validator) Range(field string, value, min, max int) bool {
+	return v.Check(value >= min && value <= max, field, fmt.Sprintf("must be between %d and %d", min, max))
+}
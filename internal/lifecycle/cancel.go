@@ -0,0 +1,45 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrAlreadyCancelled — подписка уже отменена.
+	ErrAlreadyCancelled = errors.New("subscription is already cancelled")
+	// ErrNotStarted — последний оплачиваемый день отмены раньше начала подписки.
+	ErrNotStarted = errors.New("subscription starts after the cancellation date")
+)
+
+// Cancel отменяет подписку id с последним оплачиваемым днём end: обрезает пробный
+// период и паузы по новому end_date, выключает автопродление и пересчитывает статус.
+// Проверки (подписка не отменена, end не раньше start_date) выполняет вызывающий
+// и сообщает о них ошибками ErrAlreadyCancelled и ErrNotStarted.
+func Cancel(ctx context.Context, tx pgx.Tx, id string, end time.Time, reason string, comment *string) error {
+	query := `
+		UPDATE subscriptions
+		SET end_date = $1::date,
+		    trial_end = CASE WHEN trial_end > $1::date THEN $1::date ELSE trial_end END,
+		    cancel_reason = $2, cancel_comment = $3, cancelled_at = now(), auto_renew = false
+		WHERE id = $4
+	`
+	if _, err := tx.Exec(ctx, query, end, reason, comment, id); err != nil {
+		return err
+	}
+
+	// Паузы не должны выходить за новый конец подписки.
+	if _, err := tx.Exec(ctx, `DELETE FROM subscription_pauses WHERE subscription_id = $1 AND pause_from > $2`, id, end); err != nil {
+		return err
+	}
+	query = `UPDATE subscription_pauses SET pause_until = $2 WHERE subscription_id = $1 AND (pause_until IS NULL OR pause_until > $2)`
+	if _, err := tx.Exec(ctx, query, id, end); err != nil {
+		return err
+	}
+
+	_, err := Sync(ctx, tx, id)
+	return err
+}
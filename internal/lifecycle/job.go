@@ -0,0 +1,59 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"SubServices/internal/storage"
+)
+
+// StatusJob переводит подписки в статус, соответствующий текущей дате: начало
+// подписки, окончание пробного периода, начало и конец паузы, окончание подписки.
+type StatusJob struct {
+	db *pgxpool.Pool
+}
+
+func NewStatusJob(db *pgxpool.Pool) *StatusJob {
+	return &StatusJob{db: db}
+}
+
+func (j *StatusJob) Name() string {
+	return "subscription_statuses"
+}
+
+func (j *StatusJob) Run(ctx context.Context) error {
+	query := `SELECT id FROM subscriptions WHERE status <> ` + StatusSQL
+	rows, err := j.db.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		err := storage.InTx(ctx, j.db, func(tx pgx.Tx) error {
+			_, err := Sync(ctx, tx, id)
+			return err
+		})
+		var terr *TransitionError
+		switch {
+		case errors.As(err, &terr):
+			slog.Warn("Skipping subscription status transition",
+				slog.String("subscription_id", id),
+				slog.String("from", string(terr.From)),
+				slog.String("to", string(terr.To)))
+		case errors.Is(err, pgx.ErrNoRows):
+			// подписку удалили между выборкой и обновлением
+		case err != nil:
+			return err
+		}
+	}
+
+	return nil
+}
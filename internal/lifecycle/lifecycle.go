@@ -0,0 +1,123 @@
+// Package lifecycle описывает статусы подписки, допустимые переходы между ними и
+// пересчёт статуса по датам подписки, пробному периоду, паузам и отмене.
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+
+	"SubServices/internal/events"
+	"SubServices/internal/outbox"
+)
+
+type Status string
+
+const (
+	// Scheduled — подписка ещё не началась.
+	Scheduled Status = "scheduled"
+	// Trial — идёт пробный период.
+	Trial Status = "trial"
+	// Active — подписка оплачивается по полной цене.
+	Active Status = "active"
+	// Paused — сегодня действует пауза.
+	Paused Status = "paused"
+	// Cancelled — подписка отменена через /cancel; оплачивается до end_date.
+	Cancelled Status = "cancelled"
+	// Expired — подписка закончилась без отмены.
+	Expired Status = "expired"
+)
+
+// Statuses — все статусы в порядке жизненного цикла.
+var Statuses = []Status{Scheduled, Trial, Active, Paused, Cancelled, Expired}
+
+// transitions — таблица допустимых переходов. Expired — конечный статус;
+// отменённая подписка может быть возобновлена изменением end_date.
+var transitions = map[Status][]Status{
+	Scheduled: {Trial, Active, Paused, Cancelled, Expired},
+	Trial:     {Active, Paused, Cancelled, Expired},
+	Active:    {Paused, Cancelled, Expired},
+	Paused:    {Trial, Active, Cancelled, Expired},
+	Cancelled: {Scheduled, Trial, Active, Paused, Expired},
+	Expired:   {},
+}
+
+func ParseStatus(v string) (Status, error) {
+	s := Status(v)
+	if !slices.Contains(Statuses, s) {
+		return "", fmt.Errorf("unknown status %q", v)
+	}
+	return s, nil
+}
+
+// CanTransition сообщает, разрешён ли переход from → to. Переход в тот же статус разрешён всегда.
+func CanTransition(from, to Status) bool {
+	return from == to || slices.Contains(transitions[from], to)
+}
+
+// TransitionError — изменение подписки привело бы к запрещённому переходу.
+type TransitionError struct {
+	From, To Status
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("transition from %s to %s is not allowed", e.From, e.To)
+}
+
+// StatusSQL вычисляет статус строки subscriptions на текущую дату.
+// Порядок проверок задаёт приоритет: отмена важнее окончания, пауза — пробного периода.
+// Подписка с auto_renew не истекает: её продлевает RenewalJob.
+const StatusSQL = `CASE
+		WHEN subscriptions.cancelled_at IS NOT NULL THEN 'cancelled'
+		WHEN subscriptions.end_date < CURRENT_DATE AND NOT subscriptions.auto_renew THEN 'expired'
+		WHEN subscriptions.start_date > CURRENT_DATE THEN 'scheduled'
+		WHEN EXISTS (
+			SELECT 1 FROM subscription_pauses p
+			WHERE p.subscription_id = subscriptions.id
+			AND p.pause_from <= CURRENT_DATE
+			AND (p.pause_until IS NULL OR p.pause_until >= CURRENT_DATE)
+		) THEN 'paused'
+		WHEN subscriptions.trial_end >= CURRENT_DATE THEN 'trial'
+		ELSE 'active'
+	END`
+
+// Change — событие subscription.status_changed.
+type Change struct {
+	SubscriptionID string `json:"subscription_id"`
+	UserID         string `json:"user_id"`
+	From           Status `json:"from"`
+	To             Status `json:"to"`
+}
+
+// Sync пересчитывает статус подписки id после изменения её данных в транзакции tx.
+// Если статус меняется, переход проверяется по таблице и публикуется событием;
+// запрещённый переход возвращает *TransitionError, и транзакцию нужно откатить.
+func Sync(ctx context.Context, tx pgx.Tx, id string) (Status, error) {
+	var c Change
+	query := `SELECT user_id, status, ` + StatusSQL + ` FROM subscriptions WHERE id = $1 FOR UPDATE`
+	if err := tx.QueryRow(ctx, query, id).Scan(&c.UserID, &c.From, &c.To); err != nil {
+		return "", err
+	}
+	if c.From == c.To {
+		return c.To, nil
+	}
+	if !CanTransition(c.From, c.To) {
+		return c.From, &TransitionError{From: c.From, To: c.To}
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE subscriptions SET status = $1 WHERE id = $2`, c.To, id); err != nil {
+		return "", err
+	}
+	c.SubscriptionID = id
+	return c.To, outbox.Write(ctx, tx, events.StatusChanged, c)
+}
+
+// Init задаёт статус только что созданной подписки без проверки перехода и события.
+func Init(ctx context.Context, tx pgx.Tx, id string) (Status, error) {
+	var status Status
+	query := `UPDATE subscriptions SET status = ` + StatusSQL + ` WHERE id = $1 RETURNING status`
+	err := tx.QueryRow(ctx, query, id).Scan(&status)
+	return status, err
+}
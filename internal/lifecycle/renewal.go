@@ -0,0 +1,154 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"SubServices/internal/config"
+	"SubServices/internal/events"
+	"SubServices/internal/outbox"
+	"SubServices/internal/storage"
+)
+
+// Renewal — запись истории продлений subscription_renewals.
+type Renewal struct {
+	ID             string `json:"id"`
+	SubscriptionID string `json:"subscription_id"`
+	// SuccessorID — новая подписка, если продление создаёт её (режим successor).
+	SuccessorID *string   `json:"successor_id,omitempty"`
+	Mode        string    `json:"mode"`
+	PreviousEnd time.Time `json:"previous_end"`
+	NewEnd      time.Time `json:"new_end"`
+	RenewedAt   time.Time `json:"renewed_at"`
+}
+
+// RenewalJob продлевает подписки с auto_renew, срок которых закончился. Если задача
+// не запускалась несколько сроков, подписка продлевается столько раз, сколько нужно,
+// чтобы текущий срок включал сегодняшний день; каждое продление записывается в историю.
+type RenewalJob struct {
+	db   *pgxpool.Pool
+	mode string
+}
+
+func NewRenewalJob(db *pgxpool.Pool, cfg config.RenewalsConfig) *RenewalJob {
+	return &RenewalJob{db: db, mode: cfg.Mode}
+}
+
+func (j *RenewalJob) Name() string {
+	return "subscription_renewals"
+}
+
+// renewable выбирает неотменённые подписки с auto_renew, срок которых закончился.
+const renewable = `auto_renew AND cancelled_at IS NULL AND end_date < CURRENT_DATE`
+
+func (j *RenewalJob) Run(ctx context.Context) error {
+	rows, err := j.db.Query(ctx, `SELECT id FROM subscriptions WHERE `+renewable)
+	if err != nil {
+		return err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		err := storage.InTx(ctx, j.db, func(tx pgx.Tx) error {
+			return j.renew(ctx, tx, id)
+		})
+		// ErrNoRows — подписку уже продлили, отменили или удалили.
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type term struct {
+	userID      string
+	serviceID   *string
+	serviceName string
+	price       int
+	end         time.Time
+	months      int
+}
+
+func (j *RenewalJob) renew(ctx context.Context, tx pgx.Tx, id string) error {
+	var t term
+	query := `
+		SELECT user_id, service_id, service_name, price, end_date, term_months
+		FROM subscriptions
+		WHERE id = $1 AND ` + renewable + `
+		FOR UPDATE
+	`
+	err := tx.QueryRow(ctx, query, id).Scan(&t.userID, &t.serviceID, &t.serviceName, &t.price, &t.end, &t.months)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	current := id
+	for t.end.Before(today) {
+		start := t.end.AddDate(0, 0, 1)
+		r := Renewal{
+			ID:             uuid.New().String(),
+			SubscriptionID: current,
+			Mode:           j.mode,
+			PreviousEnd:    t.end,
+			NewEnd:         start.AddDate(0, t.months, -1),
+		}
+
+		if j.mode == config.RenewalModeSuccessor {
+			successor := uuid.New().String()
+			query := `
+				INSERT INTO subscriptions (id, user_id, service_id, service_name, price, start_date, end_date, auto_renew, term_months, renewed_from)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, true, $8, $9)
+			`
+			if _, err := tx.Exec(ctx, query, successor, t.userID, t.serviceID, t.serviceName, t.price, start, r.NewEnd, t.months, current); err != nil {
+				return err
+			}
+			if _, err := Init(ctx, tx, successor); err != nil {
+				return err
+			}
+			// Продлевать дальше будет преемник, а сама подписка истекает.
+			if _, err := tx.Exec(ctx, `UPDATE subscriptions SET auto_renew = false WHERE id = $1`, current); err != nil {
+				return err
+			}
+			if _, err := Sync(ctx, tx, current); err != nil {
+				return err
+			}
+			r.SuccessorID = &successor
+		}
+
+		query := `
+			INSERT INTO subscription_renewals (id, subscription_id, successor_id, mode, previous_end, new_end)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING renewed_at
+		`
+		if err := tx.QueryRow(ctx, query, r.ID, r.SubscriptionID, r.SuccessorID, r.Mode, r.PreviousEnd, r.NewEnd).Scan(&r.RenewedAt); err != nil {
+			return err
+		}
+		if err := outbox.Write(ctx, tx, events.SubscriptionRenewed, r); err != nil {
+			return err
+		}
+
+		if r.SuccessorID != nil {
+			current = *r.SuccessorID
+		}
+		t.end = r.NewEnd
+	}
+
+	if j.mode == config.RenewalModeExtend {
+		if _, err := tx.Exec(ctx, `UPDATE subscriptions SET end_date = $1 WHERE id = $2`, t.end, id); err != nil {
+			return err
+		}
+	}
+	_, err = Sync(ctx, tx, current)
+	return err
+}
@@ -0,0 +1,140 @@
+package month
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Date — первый или последний оплачиваемый день подписки в UTC.
+//
+// В форматах с точностью до месяца дата выводится месяцем, только если лежит на
+// его границе: первое число для начала, последнее — для окончания (AsEnd).
+// Иначе выводится YYYY-MM-DD, чтобы день не потерялся.
+type Date struct {
+	t      time.Time
+	end    bool
+	format Format
+}
+
+func DateOf(year int, m time.Month, day int) Date {
+	return Date{t: time.Date(year, m, day, 0, 0, 0, 0, time.UTC)}
+}
+
+// DateFromTime отбрасывает время, оставляя день.
+func DateFromTime(t time.Time) Date {
+	return DateOf(t.Year(), t.Month(), t.Day())
+}
+
+// ParseStart принимает те же форматы, что и Parse. Месяц без дня означает его первое число.
+func ParseStart(v string) (Date, error) {
+	t, _, err := parse(v)
+	if err != nil {
+		return Date{}, err
+	}
+	return DateFromTime(t), nil
+}
+
+// ParseEnd принимает те же форматы, что и Parse. Месяц без дня означает его последнее
+// число: подписка до 12-2025 действует весь декабрь.
+func ParseEnd(v string) (Date, error) {
+	t, monthOnly, err := parse(v)
+	if err != nil {
+		return Date{}, err
+	}
+	if monthOnly {
+		t = t.AddDate(0, 1, -1)
+	}
+	return DateFromTime(t).AsEnd(), nil
+}
+
+func parse(v string) (t time.Time, monthOnly bool, err error) {
+	v = strings.TrimSpace(v)
+	for _, layout := range inputLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, layout != layouts[FormatDate], nil
+		}
+	}
+	return time.Time{}, false, ErrFormat
+}
+
+// AsEnd помечает дату как последний день периода.
+func (d Date) AsEnd() Date {
+	d.end = true
+	return d
+}
+
+func (d Date) Time() time.Time { return d.t }
+
+func (d Date) IsZero() bool { return d.t.IsZero() }
+
+func (d Date) Before(o Date) bool { return d.t.Before(o.t) }
+
+func (d Date) After(o Date) bool { return d.t.After(o.t) }
+
+func (d Date) Month() Month { return FromTime(d.t) }
+
+// In возвращает копию даты, которая выводится в формате f.
+func (d Date) In(f Format) Date {
+	d.format = f
+	return d
+}
+
+// onBoundary сообщает, можно ли вывести дату месяцем без потери дня.
+func (d Date) onBoundary() bool {
+	if d.end {
+		return d.t.AddDate(0, 0, 1).Day() == 1
+	}
+	return d.t.Day() == 1
+}
+
+func (d Date) String() string {
+	f := d.format
+	if f == "" {
+		f = Default
+	}
+	if (f == FormatMonthYear || f == FormatISO) && !d.onBoundary() {
+		f = FormatDate
+	}
+	return d.t.Format(layouts[f])
+}
+
+func (d Date) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Date) UnmarshalText(b []byte) error {
+	fn := ParseStart
+	if d.end {
+		fn = ParseEnd
+	}
+	parsed, err := fn(string(b))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Scan читает значение столбца DATE.
+func (d *Date) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*d = Date{}
+	case time.Time:
+		*d = DateFromTime(v)
+	case string:
+		return d.UnmarshalText([]byte(v))
+	default:
+		return fmt.Errorf("month: cannot scan %T", src)
+	}
+	return nil
+}
+
+func (d Date) Value() (driver.Value, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	return d.t, nil
+}
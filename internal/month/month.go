@@ -0,0 +1,145 @@
+// Package month описывает месяцы и даты подписок: разбор из запросов, вывод в
+// выбранном клиентом формате и хранение в столбцах DATE.
+package month
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Format — формат вывода месяца, который клиент выбирает параметром date_format.
+type Format string
+
+const (
+	FormatMonthYear Format = "mm-yyyy"
+	FormatISO       Format = "yyyy-mm"
+	FormatDate      Format = "yyyy-mm-dd"
+	// FormatRFC3339 повторяет прежний вывод time.Time для старых клиентов.
+	FormatRFC3339 Format = "rfc3339"
+
+	Default = FormatMonthYear
+)
+
+var layouts = map[Format]string{
+	FormatMonthYear: "01-2006",
+	FormatISO:       "2006-01",
+	FormatDate:      "2006-01-02",
+	FormatRFC3339:   time.RFC3339,
+}
+
+// inputLayouts — форматы, которые принимаются на вход.
+var inputLayouts = []string{"2006-01-02", "2006-01", "01-2006"}
+
+var ErrFormat = errors.New("must be in YYYY-MM, MM-YYYY or YYYY-MM-DD format")
+
+// ParseFormat проверяет значение date_format; пустая строка означает формат по умолчанию.
+func ParseFormat(v string) (Format, error) {
+	if v == "" {
+		return Default, nil
+	}
+	f := Format(strings.ToLower(v))
+	if _, ok := layouts[f]; !ok {
+		return "", fmt.Errorf("unknown date format %q", v)
+	}
+	return f, nil
+}
+
+type ctxKey struct{}
+
+// WithFormat сохраняет в контексте запроса формат, в котором клиент ждёт даты.
+func WithFormat(ctx context.Context, f Format) context.Context {
+	return context.WithValue(ctx, ctxKey{}, f)
+}
+
+// FormatFrom возвращает формат из контекста или Default.
+func FormatFrom(ctx context.Context) Format {
+	if f, ok := ctx.Value(ctxKey{}).(Format); ok {
+		return f
+	}
+	return Default
+}
+
+// Month — первое число месяца в UTC. Нулевое значение означает «не задан».
+type Month struct {
+	t      time.Time
+	format Format
+}
+
+func Of(year int, m time.Month) Month {
+	return Month{t: time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+// FromTime отбрасывает день и время, оставляя месяц.
+func FromTime(t time.Time) Month {
+	return Of(t.Year(), t.Month())
+}
+
+// Parse принимает YYYY-MM, MM-YYYY и YYYY-MM-DD; день в последнем случае отбрасывается.
+func Parse(v string) (Month, error) {
+	t, _, err := parse(v)
+	if err != nil {
+		return Month{}, err
+	}
+	return FromTime(t), nil
+}
+
+func (m Month) Time() time.Time { return m.t }
+
+func (m Month) IsZero() bool { return m.t.IsZero() }
+
+func (m Month) Before(o Month) bool { return m.t.Before(o.t) }
+
+func (m Month) After(o Month) bool { return m.t.After(o.t) }
+
+// In возвращает копию месяца, которая выводится в формате f.
+func (m Month) In(f Format) Month {
+	m.format = f
+	return m
+}
+
+func (m Month) String() string {
+	f := m.format
+	if f == "" {
+		f = Default
+	}
+	return m.t.Format(layouts[f])
+}
+
+func (m Month) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Month) UnmarshalText(b []byte) error {
+	parsed, err := Parse(string(b))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan читает значение столбца DATE.
+func (m *Month) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*m = Month{}
+	case time.Time:
+		*m = FromTime(v)
+	case string:
+		return m.UnmarshalText([]byte(v))
+	default:
+		return fmt.Errorf("month: cannot scan %T", src)
+	}
+	return nil
+}
+
+func (m Month) Value() (driver.Value, error) {
+	if m.IsZero() {
+		return nil, nil
+	}
+	return m.t, nil
+}
@@ -0,0 +1,126 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"SubServices/internal/config"
+	"SubServices/internal/events"
+)
+
+// Dispatcher вычитывает необработанные записи outbox и передаёт их publisher.
+// Запись помечается обработанной в той же транзакции, в которой была заблокирована,
+// поэтому при падении после публикации событие будет отправлено повторно:
+// доставка гарантируется «как минимум один раз», получатели должны быть идемпотентны по event_id.
+type Dispatcher struct {
+	db        *pgxpool.Pool
+	publisher events.Publisher
+	cfg       config.OutboxConfig
+
+	lastCleanup time.Time
+}
+
+const cleanupInterval = time.Hour
+
+func NewDispatcher(db *pgxpool.Pool, publisher events.Publisher, cfg config.OutboxConfig) *Dispatcher {
+	return &Dispatcher{db: db, publisher: publisher, cfg: cfg}
+}
+
+func (d *Dispatcher) Name() string {
+	return "outbox"
+}
+
+func (d *Dispatcher) Run(ctx context.Context) error {
+	for {
+		n, err := d.drainBatch(ctx)
+		if err != nil {
+			return err
+		}
+		if n < d.cfg.BatchSize {
+			break
+		}
+	}
+
+	return d.cleanup(ctx)
+}
+
+// drainBatch публикует одну пачку событий по порядку. Пачка обрабатывается до
+// первой ошибки публикации: уже опубликованные записи фиксируются, остальные
+// остаются в outbox до следующего запуска. Запись, которую не удаётся разобрать,
+// никогда не будет опубликована: она логируется и помечается обработанной, чтобы не
+// блокировать очередь.
+func (d *Dispatcher) drainBatch(ctx context.Context) (int, error) {
+	tx, err := d.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		SELECT id, payload
+		FROM outbox
+		WHERE processed_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+	rows, err := tx.Query(ctx, query, d.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	type record struct {
+		ID      int64
+		Payload []byte
+	}
+	batch, err := pgx.CollectRows(rows, pgx.RowToStructByPos[record])
+	if err != nil {
+		return 0, err
+	}
+
+	var processed []int64
+	var publishErr error
+	for _, rec := range batch {
+		var e events.Event
+		if err := json.Unmarshal(rec.Payload, &e); err != nil {
+			slog.Error("Skipping undecodable outbox record", slog.Int64("id", rec.ID), slog.Any("error", err))
+			processed = append(processed, rec.ID)
+			continue
+		}
+		if publishErr = d.publisher.Publish(ctx, e); publishErr != nil {
+			break
+		}
+		processed = append(processed, rec.ID)
+	}
+
+	if len(processed) > 0 {
+		_, err = tx.Exec(ctx, `UPDATE outbox SET processed_at = now() WHERE id = ANY($1)`, processed)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	if publishErr != nil {
+		return len(processed), publishErr
+	}
+	return len(batch), nil
+}
+
+func (d *Dispatcher) cleanup(ctx context.Context) error {
+	if d.cfg.Retention <= 0 || time.Since(d.lastCleanup) < cleanupInterval {
+		return nil
+	}
+	d.lastCleanup = time.Now()
+
+	query := `DELETE FROM outbox WHERE processed_at < $1`
+	_, err := d.db.Exec(ctx, query, time.Now().Add(-d.cfg.Retention))
+	return err
+}
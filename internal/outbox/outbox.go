@@ -0,0 +1,51 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"SubServices/internal/events"
+)
+
+// Execer — общий интерфейс pgx.Tx и pgxpool.Pool для записи в outbox.
+type Execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// Write сохраняет событие в outbox. Чтобы событие не потерялось и не было
+// опубликовано без изменения данных, db должен быть транзакцией этого изменения.
+func Write(ctx context.Context, db Execer, typ string, data any) error {
+	e, err := events.New(typ, data)
+	if err != nil {
+		return err
+	}
+	return insert(ctx, db, e)
+}
+
+func insert(ctx context.Context, db Execer, e events.Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO outbox (event_id, event_type, payload) VALUES ($1, $2, $3)`
+	_, err = db.Exec(ctx, query, e.ID, e.Type, payload)
+	return err
+}
+
+// Publisher записывает события в outbox вне транзакции. Используется источниками
+// событий, не связанными с изменением данных, например планировщиком.
+type Publisher struct {
+	db *pgxpool.Pool
+}
+
+func NewPublisher(db *pgxpool.Pool) *Publisher {
+	return &Publisher{db: db}
+}
+
+func (p *Publisher) Publish(ctx context.Context, e events.Event) error {
+	return insert(ctx, p.db, e)
+}
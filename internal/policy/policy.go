@@ -0,0 +1,107 @@
+// Package policy решает, что разрешено вызывающему в зависимости от его роли:
+// admin работает с данными всех пользователей и управляет справочниками,
+// manager читает данные пользователей своих команд, user — только свои.
+package policy
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"SubServices/internal/http/auth"
+)
+
+type Action int
+
+const (
+	// Read — чтение данных пользователя: подписок, бюджетов, отчётов.
+	Read Action = iota
+	// Write — изменение данных пользователя.
+	Write
+	// Manage — операции над справочниками и настройками сервиса, не
+	// привязанные к пользователю: каталог, webhook'и, API-ключи, команды.
+	Manage
+)
+
+var ErrForbidden = errors.New("forbidden")
+
+type Policy struct {
+	db *pgxpool.Pool
+}
+
+func New(db *pgxpool.Pool) *Policy {
+	return &Policy{db: db}
+}
+
+// Authorize проверяет, может ли вызывающий выполнить action над данными
+// пользователя ownerID (для Manage ownerID не используется). Без аутентификации
+// разрешено всё.
+func (p *Policy) Authorize(ctx context.Context, action Action, ownerID string) error {
+	caller, ok := auth.FromContext(ctx)
+	if !ok || caller.Role == auth.RoleAdmin {
+		return nil
+	}
+
+	switch {
+	case action == Manage:
+		return ErrForbidden
+	case caller.Subject == ownerID:
+		return nil
+	case action == Read && caller.Role == auth.RoleManager:
+		inTeam, err := p.sharesTeam(ctx, caller.Subject, ownerID)
+		if err != nil {
+			return err
+		}
+		if inTeam {
+			return nil
+		}
+	}
+	return ErrForbidden
+}
+
+// Visible возвращает пользователей, чьи данные вызывающий может читать, для
+// ограничения списков и отчётов. nil означает «все пользователи».
+func (p *Policy) Visible(ctx context.Context) ([]string, error) {
+	caller, ok := auth.FromContext(ctx)
+	if !ok || caller.Role == auth.RoleAdmin {
+		return nil, nil
+	}
+	// Данные хранятся по UUID пользователя: вызывающему с другим subject не виден никто.
+	if _, err := uuid.Parse(caller.Subject); err != nil {
+		return []string{}, nil
+	}
+	if caller.Role != auth.RoleManager {
+		return []string{caller.Subject}, nil
+	}
+
+	query := `
+		SELECT $1
+		UNION
+		SELECT m.user_id::text
+		FROM team_members m
+		JOIN team_members me ON me.team_id = m.team_id
+		WHERE me.user_id::text = $1
+	`
+	rows, err := p.db.Query(ctx, query, caller.Subject)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+func (p *Policy) sharesTeam(ctx context.Context, managerID, userID string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM team_members m
+			JOIN team_members me ON me.team_id = m.team_id
+			WHERE me.user_id::text = $1 AND m.user_id::text = $2
+		)
+	`
+	var ok bool
+	err := p.db.QueryRow(ctx, query, managerID, userID).Scan(&ok)
+	return ok, err
+}
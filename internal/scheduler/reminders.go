@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"SubServices/internal/config"
+)
+
+const (
+	KindSubscriptionEnding = "subscription_ending"
+	KindTrialEnding        = "trial_ending"
+)
+
+// dueColumns — столбец subscriptions с датой, к которой привязано напоминание каждого вида.
+var dueColumns = map[string]string{
+	KindSubscriptionEnding: "end_date",
+	KindTrialEnding:        "trial_end",
+}
+
+// Reminder — событие-напоминание, передаваемое в Sink.
+type Reminder struct {
+	Kind           string    `json:"kind"`
+	SubscriptionID string    `json:"subscription_id"`
+	UserID         string    `json:"user_id"`
+	ServiceName    string    `json:"service_name"`
+	Price          int       `json:"price"`
+	DueDate        time.Time `json:"due_date"`
+}
+
+// ReminderJob находит подписки, заканчивающиеся в пределах окна, и пробные периоды,
+// заканчивающиеся в ближайшие trial_ending_days дней, и отправляет напоминания во все
+// Sink. Отправленные напоминания фиксируются в таблице reminders отдельно для каждого
+// Sink, поэтому сбой одного получателя не вызывает повторов у других.
+type ReminderJob struct {
+	db      *pgxpool.Pool
+	windows map[string]time.Duration
+	sinks   []Sink
+}
+
+func NewReminderJob(db *pgxpool.Pool, cfg config.RemindersConfig, sinks ...Sink) *ReminderJob {
+	windows := map[string]time.Duration{
+		KindSubscriptionEnding: cfg.Window,
+		KindTrialEnding:        time.Duration(cfg.TrialEndingDays) * 24 * time.Hour,
+	}
+	return &ReminderJob{db: db, windows: windows, sinks: sinks}
+}
+
+func (j *ReminderJob) Name() string {
+	return "reminders"
+}
+
+func (j *ReminderJob) Run(ctx context.Context) error {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	for _, sink := range j.sinks {
+		var reminders []Reminder
+		for _, kind := range []string{KindSubscriptionEnding, KindTrialEnding} {
+			pending, err := j.pending(ctx, sink.Name(), kind, today, now.Add(j.windows[kind]))
+			if err != nil {
+				return err
+			}
+			reminders = append(reminders, pending...)
+		}
+
+		for _, rem := range reminders {
+			if err := sink.Send(ctx, rem); err != nil {
+				slog.Error("Failed to send reminder",
+					slog.String("sink", sink.Name()),
+					slog.String("subscription_id", rem.SubscriptionID),
+					slog.Any("error", err))
+				continue
+			}
+
+			if err := j.markSent(ctx, sink.Name(), rem); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// pending возвращает напоминания вида kind, ещё не отправленные в sink. end_date и
+// trial_end — последние дни периода, поэтому период, заканчивающийся сегодня, ещё
+// попадает в выборку.
+func (j *ReminderJob) pending(ctx context.Context, sink, kind string, from, until time.Time) ([]Reminder, error) {
+	column := dueColumns[kind]
+	query := `
+		SELECT s.id, s.user_id, s.service_name, s.price, s.` + column + `
+		FROM subscriptions s
+		WHERE s.` + column + ` IS NOT NULL
+		AND s.` + column + ` >= $1
+		AND s.` + column + ` <= $2
+		AND NOT EXISTS (
+			SELECT 1 FROM reminders r
+			WHERE r.subscription_id = s.id AND r.kind = $3 AND r.due_date = s.` + column + ` AND r.sink = $4
+		)
+	`
+	rows, err := j.db.Query(ctx, query, from, until, kind, sink)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Reminder, error) {
+		rem := Reminder{Kind: kind}
+		err := row.Scan(&rem.SubscriptionID, &rem.UserID, &rem.ServiceName, &rem.Price, &rem.DueDate)
+		return rem, err
+	})
+}
+
+func (j *ReminderJob) markSent(ctx context.Context, sink string, rem Reminder) error {
+	query := `
+		INSERT INTO reminders (subscription_id, kind, due_date, sink)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING
+	`
+	_, err := j.db.Exec(ctx, query, rem.SubscriptionID, rem.Kind, rem.DueDate, sink)
+	return err
+}
@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job — периодическая фоновая задача.
+type Job interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+// Scheduler запускает задачи сразу после старта и затем с заданным интервалом.
+type Scheduler struct {
+	interval time.Duration
+	jobs     []Job
+	trigger  chan struct{}
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New(interval time.Duration, jobs ...Job) *Scheduler {
+	return &Scheduler{interval: interval, jobs: jobs, trigger: make(chan struct{}, 1)}
+}
+
+// Trigger запускает внеочередной прогон задач, не дожидаясь интервала.
+// Несколько вызовов до начала прогона объединяются в один.
+func (s *Scheduler) Trigger() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.runJobs(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.trigger:
+			}
+		}
+	}()
+}
+
+// Stop прерывает текущий прогон и ждёт завершения задач.
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) runJobs(ctx context.Context) {
+	for _, job := range s.jobs {
+		if ctx.Err() != nil {
+			return
+		}
+
+		started := time.Now()
+		if err := job.Run(ctx); err != nil {
+			slog.Error("Scheduled job failed", slog.String("job", job.Name()), slog.Any("error", err))
+			continue
+		}
+		slog.Debug("Scheduled job finished", slog.String("job", job.Name()), slog.Duration("took", time.Since(started)))
+	}
+}
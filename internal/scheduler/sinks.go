@@ -0,0 +1,160 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"SubServices/internal/config"
+	"SubServices/internal/events"
+)
+
+// Sink доставляет напоминания получателю.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, rem Reminder) error
+}
+
+// NewSinks собирает Sink из конфигурации напоминаний.
+func NewSinks(cfg config.RemindersConfig) []Sink {
+	var sinks []Sink
+	if cfg.Log {
+		sinks = append(sinks, LogSink{})
+	}
+	if cfg.Webhook.URL != "" {
+		sinks = append(sinks, NewWebhookSink(cfg.Webhook.URL, cfg.Webhook.Timeout))
+	}
+	if cfg.SMTP.Addr != "" {
+		sinks = append(sinks, NewSMTPSink(cfg.SMTP))
+	}
+	return sinks
+}
+
+type LogSink struct{}
+
+func (LogSink) Name() string {
+	return "log"
+}
+
+func (LogSink) Send(_ context.Context, rem Reminder) error {
+	slog.Info("Subscription reminder",
+		slog.String("kind", rem.Kind),
+		slog.String("subscription_id", rem.SubscriptionID),
+		slog.String("user_id", rem.UserID),
+		slog.String("service_name", rem.ServiceName),
+		slog.Time("due_date", rem.DueDate))
+	return nil
+}
+
+// WebhookSink отправляет напоминание POST-запросом с JSON-телом.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Send(ctx context.Context, rem Reminder) error {
+	body, err := json.Marshal(rem)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SMTPSink отправляет напоминание письмом на фиксированный список адресов.
+type SMTPSink struct {
+	cfg config.SMTPSinkConfig
+}
+
+func NewSMTPSink(cfg config.SMTPSinkConfig) *SMTPSink {
+	return &SMTPSink{cfg: cfg}
+}
+
+func (s *SMTPSink) Name() string {
+	return "smtp"
+}
+
+func (s *SMTPSink) Send(_ context.Context, rem Reminder) error {
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		host, _, err := net.SplitHostPort(s.cfg.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, host)
+	}
+
+	subject := fmt.Sprintf("Подписка %s заканчивается", rem.ServiceName)
+	text := fmt.Sprintf("Подписка %s (%s) пользователя %s заканчивается %s.\r\n",
+		rem.ServiceName, rem.SubscriptionID, rem.UserID, rem.DueDate.Format("02.01.2006"))
+	if rem.Kind == KindTrialEnding {
+		subject = fmt.Sprintf("Пробный период %s заканчивается", rem.ServiceName)
+		text = fmt.Sprintf("Пробный период подписки %s (%s) пользователя %s заканчивается %s, далее — %d в месяц.\r\n",
+			rem.ServiceName, rem.SubscriptionID, rem.UserID, rem.DueDate.Format("02.01.2006"), rem.Price)
+	}
+
+	msg := "From: " + s.cfg.From + "\r\n" +
+		"To: " + strings.Join(s.cfg.To, ", ") + "\r\n" +
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + text
+
+	return smtp.SendMail(s.cfg.Addr, auth, s.cfg.From, s.cfg.To, []byte(msg))
+}
+
+// EventSink публикует напоминание как событие subscription.ending или subscription.trial_ending.
+type EventSink struct {
+	publisher events.Publisher
+}
+
+func NewEventSink(publisher events.Publisher) *EventSink {
+	return &EventSink{publisher: publisher}
+}
+
+func (s *EventSink) Name() string {
+	return "events"
+}
+
+func (s *EventSink) Send(ctx context.Context, rem Reminder) error {
+	typ := events.SubscriptionEnding
+	if rem.Kind == KindTrialEnding {
+		typ = events.TrialEnding
+	}
+	e, err := events.New(typ, rem)
+	if err != nil {
+		return err
+	}
+	return s.publisher.Publish(ctx, e)
+}
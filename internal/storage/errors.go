@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrorClass — категория ошибки БД, по которой выбирается ответ клиенту.
+type ErrorClass int
+
+const (
+	ClassUnknown ErrorClass = iota
+	// ClassNotFound — запрос не вернул строк.
+	ClassNotFound
+	// ClassInvalidInput — значение не приводится к типу столбца (22P02, 22007, 22008, 22003).
+	ClassInvalidInput
+	// ClassCheckViolation — нарушено ограничение CHECK (23514) или NOT NULL (23502).
+	ClassCheckViolation
+	// ClassUniqueViolation — нарушено ограничение уникальности (23505).
+	ClassUniqueViolation
+	// ClassForeignKeyViolation — ссылка на несуществующую запись (23503).
+	ClassForeignKeyViolation
+	// ClassSerialization — конфликт параллельных транзакций (40001, 40P01); транзакцию можно повторить.
+	ClassSerialization
+	// ClassUnavailable — нет соединения с БД, она не принимает запросы или не ответила вовремя.
+	ClassUnavailable
+)
+
+// Classify определяет категорию ошибки pgx.
+func Classify(err error) ErrorClass {
+	if err == nil {
+		return ClassUnknown
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ClassNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "22P02", "22007", "22008", "22003":
+			return ClassInvalidInput
+		case "23514", "23502":
+			return ClassCheckViolation
+		case "23505":
+			return ClassUniqueViolation
+		case "23503":
+			return ClassForeignKeyViolation
+		case "40001", "40P01":
+			return ClassSerialization
+		}
+		switch pgErr.Code[:2] {
+		case "08", "53", "57":
+			// Ошибки соединения, нехватка ресурсов, остановка сервера.
+			return ClassUnavailable
+		}
+		return ClassUnknown
+	}
+
+	var connErr *pgconn.ConnectError
+	var netErr net.Error
+	if errors.As(err, &connErr) || errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return ClassUnavailable
+	}
+	return ClassUnknown
+}
+
+// ConstraintName возвращает имя нарушенного ограничения, если оно известно.
+func ConstraintName(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.ConstraintName
+	}
+	return ""
+}
+
+// txAttempts — сколько раз выполняется транзакция при конфликтах сериализации.
+const txAttempts = 3
+
+// InTx выполняет fn в транзакции, повторяя её при ошибках сериализации и
+// взаимоблокировках с небольшой задержкой. fn должна быть идемпотентной
+// относительно всего, что происходит вне транзакции.
+func InTx(ctx context.Context, db *pgxpool.Pool, fn func(pgx.Tx) error) error {
+	var err error
+	for attempt := 1; attempt <= txAttempts; attempt++ {
+		err = pgx.BeginFunc(ctx, db, fn)
+		if Classify(err) != ClassSerialization || attempt == txAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * 20 * time.Millisecond):
+		}
+	}
+	return err
+}
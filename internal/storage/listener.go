@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SubscriptionChangesChannel — канал, в который триггер на subscriptions отправляет уведомления.
+const SubscriptionChangesChannel = "subscription_changes"
+
+const (
+	OpInsert = "INSERT"
+	OpUpdate = "UPDATE"
+	OpDelete = "DELETE"
+)
+
+// Notification — изменение подписки, полученное через LISTEN/NOTIFY.
+type Notification struct {
+	ID          string `json:"id"`
+	Op          string `json:"op"`
+	UserID      string `json:"user_id"`
+	ServiceName string `json:"service_name"`
+}
+
+// Listener держит отдельное от пула соединение с LISTEN на канале изменений
+// и раздаёт уведомления локальным подписчикам. Так изменения, сделанные любым
+// экземпляром сервиса, доходят до всех экземпляров. При обрыве соединение
+// восстанавливается с экспоненциальной задержкой.
+type Listener struct {
+	connConfig *pgx.ConnConfig
+	minDelay   time.Duration
+	maxDelay   time.Duration
+
+	mu       sync.RWMutex
+	handlers []func(context.Context, Notification)
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewListener берёт параметры подключения из конфигурации пула, созданного NewPool.
+func NewListener(pool *pgxpool.Pool, minDelay, maxDelay time.Duration) *Listener {
+	return &Listener{
+		connConfig: pool.Config().ConnConfig.Copy(),
+		minDelay:   minDelay,
+		maxDelay:   maxDelay,
+	}
+}
+
+// Subscribe регистрирует обработчик. Обработчики вызываются последовательно
+// в горутине Listener и не должны надолго блокироваться.
+func (l *Listener) Subscribe(fn func(context.Context, Notification)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handlers = append(l.handlers, fn)
+}
+
+func (l *Listener) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	l.cancel = cancel
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+
+		delay := l.minDelay
+		for {
+			connected, err := l.listen(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if connected {
+				delay = l.minDelay
+			}
+
+			slog.Error("Notification listener disconnected, notifications may be missed",
+				slog.Duration("retry_in", delay), slog.Any("error", err))
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, l.maxDelay)
+		}
+	}()
+}
+
+func (l *Listener) Stop() {
+	if l.cancel == nil {
+		return
+	}
+	l.cancel()
+	l.wg.Wait()
+}
+
+// listen подключается, подписывается на канал и читает уведомления до ошибки.
+// connected сообщает, удалось ли установить соединение.
+func (l *Listener) listen(ctx context.Context) (connected bool, err error) {
+	conn, err := pgx.ConnectConfig(ctx, l.connConfig)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{SubscriptionChangesChannel}.Sanitize()); err != nil {
+		return false, err
+	}
+	slog.Info("Listening for subscription changes", slog.String("channel", SubscriptionChangesChannel))
+
+	for {
+		pn, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+
+		var n Notification
+		if err := json.Unmarshal([]byte(pn.Payload), &n); err != nil {
+			slog.Error("Malformed notification", slog.String("payload", pn.Payload), slog.Any("error", err))
+			continue
+		}
+		l.dispatch(ctx, n)
+	}
+}
+
+func (l *Listener) dispatch(ctx context.Context, n Notification) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, fn := range l.handlers {
+		fn(ctx, n)
+	}
+}
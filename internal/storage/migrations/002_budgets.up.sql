@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS budgets(
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    service_name VARCHAR(255),
+    monthly_limit INTEGER NOT NULL CHECK (monthly_limit >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Один общий бюджет (service_name IS NULL) и не более одного бюджета на сервис.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_budgets_user_service
+    ON budgets(user_id, COALESCE(service_name, ''));
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS reminders(
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    kind VARCHAR(64) NOT NULL,
+    due_date DATE NOT NULL,
+    sink VARCHAR(64) NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (subscription_id, kind, due_date, sink)
+);
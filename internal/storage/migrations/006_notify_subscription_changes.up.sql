@@ -0,0 +1,26 @@
+CREATE OR REPLACE FUNCTION notify_subscription_change() RETURNS trigger AS $$
+DECLARE
+    rec subscriptions;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        rec := OLD;
+    ELSE
+        rec := NEW;
+    END IF;
+
+    PERFORM pg_notify('subscription_changes', json_build_object(
+        'id', rec.id,
+        'op', TG_OP,
+        'user_id', rec.user_id,
+        'service_name', rec.service_name
+    )::text);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_subscriptions_notify ON subscriptions;
+
+CREATE TRIGGER trg_subscriptions_notify
+    AFTER INSERT OR UPDATE OR DELETE ON subscriptions
+    FOR EACH ROW EXECUTE FUNCTION notify_subscription_change();
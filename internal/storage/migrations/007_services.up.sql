@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS services(
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    category VARCHAR(64),
+    default_price INTEGER CHECK (default_price >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- alias_key — нормализованное написание (catalog.Key), по нему сервис ищется при создании подписки.
+-- Каноническое название сервиса тоже хранится как алиас.
+CREATE TABLE IF NOT EXISTS service_aliases(
+    service_id UUID NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    alias VARCHAR(255) NOT NULL,
+    alias_key VARCHAR(255) NOT NULL UNIQUE
+);
+
+CREATE INDEX IF NOT EXISTS idx_service_aliases_service
+    ON service_aliases(service_id);
+
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS service_id UUID REFERENCES services(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_id
+    ON subscriptions(service_id);
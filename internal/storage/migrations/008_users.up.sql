@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS users(
+    id UUID PRIMARY KEY,
+    display_name VARCHAR(255) NOT NULL DEFAULT '',
+    email VARCHAR(320),
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email
+    ON users(lower(email));
+
+-- Пользователи, которые уже встречаются в подписках и бюджетах.
+INSERT INTO users (id)
+SELECT user_id FROM subscriptions
+UNION
+SELECT user_id FROM budgets
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE subscriptions
+    ADD CONSTRAINT fk_subscriptions_user FOREIGN KEY (user_id) REFERENCES users(id);
+
+ALTER TABLE budgets
+    ADD CONSTRAINT fk_budgets_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
@@ -0,0 +1,14 @@
+-- end_date теперь последний оплачиваемый день включительно. Раньше хранилось первое
+-- число последнего месяца подписки, поэтому такие значения переносятся на конец месяца.
+UPDATE subscriptions
+SET end_date = (date_trunc('month', end_date) + interval '1 month - 1 day')::date
+WHERE end_date IS NOT NULL
+AND end_date = date_trunc('month', end_date)::date;
+
+-- Отправленные напоминания привязаны к end_date и не должны уйти повторно.
+UPDATE reminders r
+SET due_date = s.end_date
+FROM subscriptions s
+WHERE r.subscription_id = s.id
+AND r.kind = 'subscription_ending'
+AND r.due_date = date_trunc('month', s.end_date)::date;
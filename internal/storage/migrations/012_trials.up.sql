@@ -0,0 +1,10 @@
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS trial_end DATE,
+    ADD COLUMN IF NOT EXISTS trial_price INTEGER;
+
+ALTER TABLE subscriptions
+    ADD CONSTRAINT chk_subscriptions_trial_end CHECK (trial_end >= start_date AND (end_date IS NULL OR trial_end <= end_date)),
+    ADD CONSTRAINT chk_subscriptions_trial_price CHECK (trial_price >= 0 AND trial_end IS NOT NULL);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_trial_end
+    ON subscriptions(trial_end) WHERE trial_end IS NOT NULL;
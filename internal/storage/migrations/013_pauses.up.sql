@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS subscription_pauses (
+    id UUID PRIMARY KEY,
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    pause_from DATE NOT NULL,
+    pause_until DATE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT chk_subscription_pauses_period CHECK (pause_until >= pause_from)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_pauses_subscription_id
+    ON subscription_pauses(subscription_id, pause_from);
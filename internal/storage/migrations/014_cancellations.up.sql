@@ -0,0 +1,15 @@
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS cancel_reason VARCHAR(32),
+    ADD COLUMN IF NOT EXISTS cancel_comment TEXT,
+    ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMPTZ;
+
+ALTER TABLE subscriptions
+    ADD CONSTRAINT chk_subscriptions_cancel_reason CHECK (
+        cancel_reason IN ('too_expensive', 'not_using', 'switched_service', 'technical_issues', 'other')
+    ),
+    ADD CONSTRAINT chk_subscriptions_cancelled CHECK (
+        (cancel_reason IS NULL) = (cancelled_at IS NULL) AND (cancelled_at IS NULL OR end_date IS NOT NULL)
+    );
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_cancelled_at
+    ON subscriptions(cancelled_at) WHERE cancelled_at IS NOT NULL;
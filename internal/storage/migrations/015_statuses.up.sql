@@ -0,0 +1,23 @@
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'active';
+
+UPDATE subscriptions SET status = CASE
+    WHEN subscriptions.cancelled_at IS NOT NULL THEN 'cancelled'
+    WHEN subscriptions.end_date < CURRENT_DATE THEN 'expired'
+    WHEN subscriptions.start_date > CURRENT_DATE THEN 'scheduled'
+    WHEN EXISTS (
+        SELECT 1 FROM subscription_pauses p
+        WHERE p.subscription_id = subscriptions.id
+        AND p.pause_from <= CURRENT_DATE
+        AND (p.pause_until IS NULL OR p.pause_until >= CURRENT_DATE)
+    ) THEN 'paused'
+    WHEN subscriptions.trial_end >= CURRENT_DATE THEN 'trial'
+    ELSE 'active'
+END;
+
+ALTER TABLE subscriptions
+    ADD CONSTRAINT chk_subscriptions_status CHECK (
+        status IN ('scheduled', 'trial', 'active', 'paused', 'cancelled', 'expired')
+    );
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_status ON subscriptions(status);
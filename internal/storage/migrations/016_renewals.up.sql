@@ -0,0 +1,24 @@
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS auto_renew BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS term_months INTEGER,
+    ADD COLUMN IF NOT EXISTS renewed_from UUID REFERENCES subscriptions(id) ON DELETE SET NULL;
+
+ALTER TABLE subscriptions
+    ADD CONSTRAINT chk_subscriptions_term_months CHECK (term_months BETWEEN 1 AND 120),
+    ADD CONSTRAINT chk_subscriptions_auto_renew CHECK (NOT auto_renew OR (term_months IS NOT NULL AND end_date IS NOT NULL));
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_auto_renew
+    ON subscriptions(end_date) WHERE auto_renew;
+
+CREATE TABLE IF NOT EXISTS subscription_renewals (
+    id UUID PRIMARY KEY,
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    successor_id UUID REFERENCES subscriptions(id) ON DELETE SET NULL,
+    mode VARCHAR(16) NOT NULL CHECK (mode IN ('extend', 'successor')),
+    previous_end DATE NOT NULL,
+    new_end DATE NOT NULL,
+    renewed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_renewals_subscription_id
+    ON subscription_renewals(subscription_id, renewed_at);
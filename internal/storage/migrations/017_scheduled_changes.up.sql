@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS scheduled_changes (
+    id UUID PRIMARY KEY,
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    effective_month DATE NOT NULL,
+    changes JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'applied', 'failed', 'cancelled')),
+    -- previous — значения изменённых полей до применения.
+    previous JSONB,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    applied_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_changes_subscription_id
+    ON scheduled_changes(subscription_id, effective_month);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_changes_pending
+    ON scheduled_changes(effective_month) WHERE status = 'pending';
@@ -0,0 +1,76 @@
+-- Версии строк subscriptions: версия действует в [valid_from, valid_to), valid_to IS NULL — текущая.
+CREATE TABLE IF NOT EXISTS subscriptions_history (
+    history_id BIGSERIAL PRIMARY KEY,
+    id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    service_id UUID,
+    service_name VARCHAR(255) NOT NULL,
+    price INTEGER NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE,
+    trial_end DATE,
+    trial_price INTEGER,
+    cancel_reason VARCHAR(32),
+    cancel_comment TEXT,
+    cancelled_at TIMESTAMPTZ,
+    status VARCHAR(16) NOT NULL,
+    auto_renew BOOLEAN NOT NULL,
+    term_months INTEGER,
+    renewed_from UUID,
+    valid_from TIMESTAMPTZ NOT NULL,
+    valid_to TIMESTAMPTZ,
+    CHECK (valid_to IS NULL OR valid_to > valid_from)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_history_id
+    ON subscriptions_history(id, valid_from);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_history_period
+    ON subscriptions_history(valid_from, valid_to);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subscriptions_history_current
+    ON subscriptions_history(id) WHERE valid_to IS NULL;
+
+-- Несколько изменений строки в одной транзакции дают одну версию: now() в транзакции не меняется.
+CREATE OR REPLACE FUNCTION record_subscription_history() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        DELETE FROM subscriptions_history
+        WHERE id = OLD.id AND valid_to IS NULL AND valid_from = now();
+
+        UPDATE subscriptions_history SET valid_to = now()
+        WHERE id = OLD.id AND valid_to IS NULL;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO subscriptions_history (
+            id, user_id, service_id, service_name, price, start_date, end_date, trial_end, trial_price,
+            cancel_reason, cancel_comment, cancelled_at, status, auto_renew, term_months, renewed_from,
+            valid_from
+        ) VALUES (
+            NEW.id, NEW.user_id, NEW.service_id, NEW.service_name, NEW.price, NEW.start_date, NEW.end_date,
+            NEW.trial_end, NEW.trial_price, NEW.cancel_reason, NEW.cancel_comment, NEW.cancelled_at,
+            NEW.status, NEW.auto_renew, NEW.term_months, NEW.renewed_from, now()
+        );
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Для существующих подписок история неизвестна: текущее состояние считается действующим всегда.
+INSERT INTO subscriptions_history (
+    id, user_id, service_id, service_name, price, start_date, end_date, trial_end, trial_price,
+    cancel_reason, cancel_comment, cancelled_at, status, auto_renew, term_months, renewed_from,
+    valid_from
+)
+SELECT id, user_id, service_id, service_name, price, start_date, end_date, trial_end, trial_price,
+       cancel_reason, cancel_comment, cancelled_at, status, auto_renew, term_months, renewed_from,
+       '-infinity'
+FROM subscriptions;
+
+DROP TRIGGER IF EXISTS trg_subscriptions_history ON subscriptions;
+
+CREATE TRIGGER trg_subscriptions_history
+    AFTER INSERT OR UPDATE OR DELETE ON subscriptions
+    FOR EACH ROW EXECUTE FUNCTION record_subscription_history();
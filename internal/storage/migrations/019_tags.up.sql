@@ -0,0 +1,15 @@
+-- name хранится нормализованным: в нижнем регистре, с одиночными пробелами.
+CREATE TABLE IF NOT EXISTS tags(
+    id UUID PRIMARY KEY,
+    name VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS subscription_tags(
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (subscription_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_tags_tag
+    ON subscription_tags(tag_id);
@@ -0,0 +1,6 @@
+-- catalog.Key больше не сворачивает латинское «ks» в «x»: ключи алиасов без кириллицы
+-- пересчитываются. Старые ключи уникальны, поэтому новые тоже не пересекаются.
+UPDATE service_aliases
+SET alias_key = regexp_replace(lower(alias), '[^[:alnum:]]', '', 'g')
+WHERE alias !~ '[А-Яа-яЁёІіЇїЄє]'
+AND alias_key <> regexp_replace(lower(alias), '[^[:alnum:]]', '', 'g');
@@ -0,0 +1,11 @@
+-- Смены цены отложенными изменениями: с effective_month действует price, до него — previous_price.
+-- subscriptions.price хранит текущую цену, поэтому прошлые месяцы считаются по previous_price.
+CREATE TABLE IF NOT EXISTS subscription_price_changes (
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    effective_month DATE NOT NULL,
+    price INTEGER NOT NULL CHECK (price >= 0),
+    previous_price INTEGER NOT NULL CHECK (previous_price >= 0),
+    change_id UUID REFERENCES scheduled_changes(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (subscription_id, effective_month)
+);
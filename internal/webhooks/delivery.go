@@ -0,0 +1,162 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"SubServices/internal/config"
+)
+
+const deliveryBatchSize = 50
+
+type delivery struct {
+	ID        string
+	EventType string
+	Payload   []byte
+	Attempts  int
+	URL       string
+	Secret    string
+}
+
+// DeliveryJob отправляет ожидающие доставки. Неудачные попытки повторяются с
+// экспоненциальной задержкой; после max_attempts доставка переходит в статус dead.
+type DeliveryJob struct {
+	db     *pgxpool.Pool
+	cfg    config.WebhooksConfig
+	client *http.Client
+}
+
+func NewDeliveryJob(db *pgxpool.Pool, cfg config.WebhooksConfig) *DeliveryJob {
+	return &DeliveryJob{db: db, cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}
+}
+
+func (j *DeliveryJob) Name() string {
+	return "webhook_deliveries"
+}
+
+func (j *DeliveryJob) Run(ctx context.Context) error {
+	for {
+		batch, err := j.claim(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, d := range batch {
+			status, err := j.send(ctx, d)
+			if err := j.record(ctx, d, status, err); err != nil {
+				return err
+			}
+		}
+
+		if len(batch) < deliveryBatchSize {
+			return nil
+		}
+	}
+}
+
+// claim выбирает пачку готовых к отправке доставок и сдвигает их next_attempt_at
+// на время таймаута, чтобы другие экземпляры сервиса не взяли их одновременно.
+func (j *DeliveryJob) claim(ctx context.Context) ([]delivery, error) {
+	query := `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = now() + $1 * interval '1 millisecond'
+		FROM webhook_endpoints e
+		WHERE d.endpoint_id = e.id
+		AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.event_type, d.payload, d.attempts, e.url, e.secret
+	`
+	lease := 2 * j.cfg.Timeout
+	rows, err := j.db.Query(ctx, query, lease.Milliseconds(), deliveryBatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (delivery, error) {
+		var d delivery
+		err := row.Scan(&d.ID, &d.EventType, &d.Payload, &d.Attempts, &d.URL, &d.Secret)
+		return d, err
+	})
+}
+
+func (j *DeliveryJob) send(ctx context.Context, d delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderDelivery, d.ID)
+	req.Header.Set(HeaderSignature, Sign(d.Secret, time.Now().Unix(), d.Payload))
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (j *DeliveryJob) record(ctx context.Context, d delivery, status int, sendErr error) error {
+	var respStatus *int
+	if status != 0 {
+		respStatus = &status
+	}
+
+	if sendErr == nil {
+		query := `
+			UPDATE webhook_deliveries
+			SET status = 'delivered', attempts = attempts + 1, response_status = $1,
+			    last_error = NULL, delivered_at = now()
+			WHERE id = $2
+		`
+		_, err := j.db.Exec(ctx, query, respStatus, d.ID)
+		return err
+	}
+
+	attempts := d.Attempts + 1
+	next := StatusPending
+	if attempts >= j.cfg.MaxAttempts {
+		next = StatusDead
+		slog.Warn("Webhook delivery moved to dead letter",
+			slog.String("delivery_id", d.ID),
+			slog.String("url", d.URL),
+			slog.Any("error", sendErr))
+	}
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, response_status = $3, last_error = $4,
+		    next_attempt_at = now() + $5 * interval '1 millisecond'
+		WHERE id = $6
+	`
+	_, err := j.db.Exec(ctx, query, next, attempts, respStatus, sendErr.Error(), j.backoff(attempts).Milliseconds(), d.ID)
+	return err
+}
+
+// backoff возвращает задержку перед следующей попыткой: base * 2^(attempts-1), не более max.
+func (j *DeliveryJob) backoff(attempts int) time.Duration {
+	delay := j.cfg.BackoffBase
+	for i := 1; i < attempts && delay < j.cfg.BackoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, j.cfg.BackoffMax)
+}
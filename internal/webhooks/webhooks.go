@@ -0,0 +1,113 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"SubServices/internal/events"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+var (
+	ErrMalformedSignature = errors.New("malformed signature header")
+	ErrStaleSignature     = errors.New("signature timestamp out of tolerance")
+	ErrSignatureMismatch  = errors.New("signature mismatch")
+)
+
+// Publisher ставит событие в очередь доставки для каждого активного
+// эндпоинта, подписанного на его тип.
+type Publisher struct {
+	db *pgxpool.Pool
+}
+
+func NewPublisher(db *pgxpool.Pool) *Publisher {
+	return &Publisher{db: db}
+}
+
+func (p *Publisher) Publish(ctx context.Context, e events.Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO webhook_deliveries (id, endpoint_id, event_id, event_type, payload)
+		SELECT gen_random_uuid(), id, $1, $2, $3
+		FROM webhook_endpoints
+		WHERE active AND $2 = ANY(events)
+	`
+	_, err = p.db.Exec(ctx, query, e.ID, e.Type, payload)
+	return err
+}
+
+// Sign возвращает значение заголовка X-Webhook-Signature: HMAC-SHA256 от
+// строки "<timestamp>.<body>". Получатель проверяет подпись тем же секретом
+// и отбрасывает запросы со слишком старым timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	return "t=" + strconv.FormatInt(timestamp, 10) + ",v1=" + signature(secret, timestamp, body)
+}
+
+// signature — HMAC-SHA256 строки "<timestamp>.<body>" в hex.
+func signature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify проверяет значение заголовка X-Webhook-Signature. Подписи старше
+// tolerance отклоняются, чтобы перехваченный запрос нельзя было повторить.
+// Заголовок может содержать несколько v1 (например, при смене секрета) и другие
+// версии подписи в любом порядке; достаточно совпадения одной v1.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var (
+		ts   string
+		sigs []string
+	)
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+
+	timestamp, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrMalformedSignature
+	}
+	if age := time.Since(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return ErrStaleSignature
+	}
+
+	expected := []byte(signature(secret, timestamp, body))
+	for _, sig := range sigs {
+		if hmac.Equal(expected, []byte(sig)) {
+			return nil
+		}
+	}
+	return ErrSignatureMismatch
+}
@@ -0,0 +1,65 @@
+package webhooks
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"type":"subscription.created"}`)
+	now := time.Now().Unix()
+	ts := strconv.FormatInt(now, 10)
+	valid := signature(secret, now, body)
+	other := signature("whsec_other", now, body)
+
+	tests := []struct {
+		name   string
+		header string
+		body   []byte
+		want   error
+	}{
+		{name: "valid", header: Sign(secret, now, body), want: nil},
+		{name: "spaces around parts", header: " t=" + ts + " , v1=" + valid + " ", want: nil},
+		{name: "several v1, match last", header: "t=" + ts + ",v1=" + other + ",v1=" + valid, want: nil},
+		{name: "several v1, match first", header: "v1=" + valid + ",t=" + ts + ",v1=" + other, want: nil},
+		{name: "other versions ignored", header: "t=" + ts + ",v0=deadbeef,v1=" + valid, want: nil},
+		{name: "wrong secret", header: Sign("whsec_other", now, body), want: ErrSignatureMismatch},
+		{name: "several v1, none match", header: "t=" + ts + ",v1=" + other + ",v1=abc", want: ErrSignatureMismatch},
+		{name: "tampered body", header: Sign(secret, now, body), body: []byte(`{"type":"subscription.deleted"}`), want: ErrSignatureMismatch},
+		{name: "timestamp not covered by signature", header: "t=" + strconv.FormatInt(now-1, 10) + ",v1=" + valid, want: ErrSignatureMismatch},
+		{name: "uppercase hex", header: "t=" + ts + ",v1=" + "ABC" + valid[3:], want: ErrSignatureMismatch},
+		{name: "stale timestamp", header: Sign(secret, now-int64(10*time.Minute/time.Second), body), want: ErrStaleSignature},
+		{name: "future timestamp", header: Sign(secret, now+int64(10*time.Minute/time.Second), body), want: ErrStaleSignature},
+		{name: "empty header", header: "", want: ErrMalformedSignature},
+		{name: "missing t", header: "v1=" + valid, want: ErrMalformedSignature},
+		{name: "missing v1", header: "t=" + ts, want: ErrMalformedSignature},
+		{name: "only other versions", header: "t=" + ts + ",v0=" + valid, want: ErrMalformedSignature},
+		{name: "non-numeric t", header: "t=now,v1=" + valid, want: ErrMalformedSignature},
+		{name: "t without value", header: "t,v1=" + valid, want: ErrMalformedSignature},
+		{name: "garbage", header: "not a signature", want: ErrMalformedSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := body
+			if tt.body != nil {
+				b = tt.body
+			}
+			if err := Verify(secret, tt.header, b, 5*time.Minute); !errors.Is(err, tt.want) {
+				t.Fatalf("Verify(%q) error = %v, want %v", tt.header, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	// Эталон: HMAC-SHA256("secret", "1700000000.{}").
+	got := Sign("secret", 1700000000, []byte("{}"))
+	want := "t=1700000000,v1=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163"
+	if got != want {
+		t.Fatalf("Sign() = %q, want %q", got, want)
+	}
+}